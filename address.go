@@ -0,0 +1,135 @@
+package anonymizer
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Address is a postal address split into its components.
+type Address struct {
+	Street     string `json:"street"`
+	City       string `json:"city"`
+	State      string `json:"state"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+}
+
+// String formats the address as "street, city, state postal, country",
+// leaving out the empty components.
+func (a Address) String() string {
+	var parts []string
+	for _, part := range []string{a.Street, a.City, strings.TrimSpace(a.State + " " + a.PostalCode), a.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (a *Address) component(name string) *string {
+	switch name {
+	case "street":
+		return &a.Street
+	case "city":
+		return &a.City
+	case "state":
+		return &a.State
+	case "postal", "postal_code", "zip":
+		return &a.PostalCode
+	case "country":
+		return &a.Country
+	}
+	return nil
+}
+
+// ParseAddress splits the first address matching AddressRegex in s into
+// its components.
+func ParseAddress(s string) (Address, bool) {
+	a, _, ok := findAddress(s)
+	return a, ok
+}
+
+// findAddress returns the first address in s and its location.
+func findAddress(s string) (Address, []int, bool) {
+	m := AddressRegex.FindStringSubmatchIndex(s)
+	if m == nil {
+		return Address{}, nil, false
+	}
+	var a Address
+	for i, name := range AddressRegex.SubexpNames() {
+		if field := a.component(name); field != nil && m[2*i] >= 0 {
+			*field = s[m[2*i]:m[2*i+1]]
+		}
+	}
+	return a, m[:2], true
+}
+
+// ParseAddresses returns the addresses found in data.
+func ParseAddresses(data string) []string {
+	return AddressRegex.FindAllString(data, -1)
+}
+
+// AddressAnonymizer replaces addresses. Its parameter is a mode optionally
+// followed by the components it applies to, e.g. "redact:street,postal":
+//
+//	generalize  keeps only the city and the country (the default)
+//	fake        replaces components with fake ones, the same for the same address
+//	redact      masks components with "*", the street and postal code by default
+type AddressAnonymizer struct {
+	Salt string `json:"salt"`
+}
+
+func (a *AddressAnonymizer) Replace(source any, name string) any {
+	switch field := source.(type) {
+	case reflect.Value:
+		field = indirect(field)
+		if field.Kind() != reflect.String {
+			return replacedValue(field)
+		}
+		value := field.String()
+		address, loc, ok := findAddress(value)
+		if !ok {
+			return value
+		}
+		mode, list, _ := strings.Cut(name, ":")
+		var components []string
+		if list != "" {
+			components = strings.Split(list, ",")
+		}
+		// The text around the address is kept.
+		return value[:loc[0]] + a.anonymize(address, value[loc[0]:loc[1]], mode, components).String() + value[loc[1]:]
+	default:
+		return source
+	}
+}
+
+func (a *AddressAnonymizer) anonymize(address Address, value, mode string, components []string) Address {
+	switch mode {
+	case "fake":
+		if len(components) == 0 {
+			components = []string{"street", "city", "state", "postal"}
+		}
+		faker := seededFaker(value, a.Salt)
+		fakes := map[string]func() string{
+			"street": faker.Street, "city": faker.City, "state": faker.StateAbr,
+			"postal": faker.Zip, "postal_code": faker.Zip, "zip": faker.Zip, "country": faker.Country,
+		}
+		for _, name := range components {
+			if field, fake := address.component(name), fakes[name]; field != nil && *field != "" && fake != nil {
+				*field = fake()
+			}
+		}
+	case "redact":
+		if len(components) == 0 {
+			components = []string{"street", "postal"}
+		}
+		for _, name := range components {
+			if field := address.component(name); field != nil {
+				*field = strings.Repeat("*", len([]rune(*field)))
+			}
+		}
+	default:
+		address = Address{City: address.City, Country: address.Country}
+	}
+	return address
+}
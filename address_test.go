@@ -0,0 +1,76 @@
+package anonymizer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		text string
+		want Address
+		ok   bool
+	}{
+		{"123 Main St, Springfield, IL 62704, USA", Address{Street: "123 Main St", City: "Springfield", State: "IL", PostalCode: "62704", Country: "USA"}, true},
+		{"Ship to 123 Main St, Springfield, IL 62704 by Monday", Address{Street: "123 Main St", City: "Springfield", State: "IL", PostalCode: "62704"}, true},
+		{"10 Downing Street, London, SW1A 2AA, UK", Address{Street: "10 Downing Street", City: "London", PostalCode: "SW1A 2AA", Country: "UK"}, true},
+		{"Doe, John", Address{}, false},
+		{"red, green, blue", Address{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseAddress(tt.text)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseAddress(%q) = %+v, %v, want %+v, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestAddressAnonymizer(t *testing.T) {
+	tests := []struct {
+		text, mode, want string
+	}{
+		{"123 Main St, Springfield, IL 62704, USA", "", "Springfield, USA"},
+		{"123 Main St, Springfield, IL 62704, USA", "redact", "***********, Springfield, IL *****, USA"},
+		{"123 Main St, Springfield, IL 62704, USA", "redact:city", "123 Main St, ***********, IL 62704, USA"},
+		{"Ship to 123 Main St, Springfield, IL 62704 by Monday", "generalize", "Ship to Springfield by Monday"},
+		{"Ship to 123 Main St, Springfield, IL 62704 by Monday", "redact:postal", "Ship to 123 Main St, Springfield, IL ***** by Monday"},
+		{"Doe, John", "", "Doe, John"},
+	}
+	a := &AddressAnonymizer{}
+	for _, tt := range tests {
+		if got := replaceString(a, tt.text, tt.mode); got != tt.want {
+			t.Errorf("Replace(%q, %q) = %q, want %q", tt.text, tt.mode, got, tt.want)
+		}
+	}
+	fake := replaceString(a, "Ship to 123 Main St, Springfield, IL 62704 by Monday", "fake:street")
+	if fake != replaceString(a, "Ship to 123 Main St, Springfield, IL 62704 by Monday", "fake:street") {
+		t.Errorf("fake address %q is not stable", fake)
+	}
+	if !strings.HasPrefix(fake, "Ship to ") || !strings.HasSuffix(fake, ", Springfield, IL 62704 by Monday") {
+		t.Errorf("fake address %q does not keep the text around", fake)
+	}
+}
+
+func TestAddressAnonymizerWithoutAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		data any
+		want string
+	}{
+		{"no address", map[string]any{"v": "n/a"}, `{"v":"n/a"}`},
+		{"not a string", map[string]any{"v": 12}, `{"v":12}`},
+		{"struct", struct {
+			Home string `json:"home"`
+		}{"n/a"}, `{"home":"n/a"}`},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(Anonymize(tt.data, Rule{Type: "address", Value: "redact"}))
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%s: Anonymize = %s, %v, want %s", tt.name, got, err, tt.want)
+		}
+	}
+	if got := replaceString(&AddressAnonymizer{}, "call me", "fake"); got != "call me" {
+		t.Errorf("Replace = %q", got)
+	}
+}
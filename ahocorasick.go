@@ -0,0 +1,75 @@
+package anonymizer
+
+// ahoCorasick is a case-insensitive (ASCII) multi-literal matcher compiled
+// into a full transition table.
+type ahoCorasick struct {
+	delta   []int32
+	outputs [][]int
+}
+
+func newAhoCorasick(patterns []string) *ahoCorasick {
+	ac := &ahoCorasick{}
+	ac.addState()
+	for idx, pattern := range patterns {
+		state := int32(0)
+		for i := 0; i < len(pattern); i++ {
+			b := lowerASCII(pattern[i])
+			next := ac.delta[int(state)*256+int(b)]
+			if next == 0 {
+				next = ac.addState()
+				ac.delta[int(state)*256+int(b)] = next
+			}
+			state = next
+		}
+		ac.outputs[state] = append(ac.outputs[state], idx)
+	}
+	// Breadth-first pass turning the trie into a DFA and merging the
+	// outputs of the failure states.
+	fail := make([]int32, len(ac.outputs))
+	var queue []int32
+	for b := 0; b < 256; b++ {
+		if next := ac.delta[b]; next != 0 {
+			queue = append(queue, next)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		ac.outputs[state] = append(ac.outputs[state], ac.outputs[fail[state]]...)
+		for b := 0; b < 256; b++ {
+			next := ac.delta[int(state)*256+b]
+			if next != 0 {
+				fail[next] = ac.delta[int(fail[state])*256+b]
+				queue = append(queue, next)
+			} else {
+				ac.delta[int(state)*256+b] = ac.delta[int(fail[state])*256+b]
+			}
+		}
+	}
+	return ac
+}
+
+func (ac *ahoCorasick) addState() int32 {
+	ac.delta = append(ac.delta, make([]int32, 256)...)
+	ac.outputs = append(ac.outputs, nil)
+	return int32(len(ac.outputs) - 1)
+}
+
+// findAll calls fn with the pattern index and the end offset of every
+// occurrence of a pattern in text.
+func (ac *ahoCorasick) findAll(text string, fn func(pattern, end int)) {
+	state := int32(0)
+	for i := 0; i < len(text); i++ {
+		state = ac.delta[int(state)*256+int(lowerASCII(text[i]))]
+		for _, pattern := range ac.outputs[state] {
+			fn(pattern, i+1)
+		}
+	}
+}
+
+func lowerASCII(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
@@ -1,7 +1,10 @@
 package anonymizer
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"reflect"
 	"strings"
 )
@@ -14,6 +17,8 @@ type Rule struct {
 	Type  string `json:"type"`
 	Value string `json:"value"`
 	Field string `json:"field"`
+	Allow *List  `json:"allow,omitempty"`
+	Deny  *List  `json:"deny,omitempty"`
 }
 
 func AnonymizeStruct(val reflect.Value, rules ...Rule) any {
@@ -37,47 +42,47 @@ func AnonymizeStruct(val reflect.Value, rules ...Rule) any {
 				outName = n
 			}
 			if outName != "" {
+				if value, ok := replaceComposite(outName, currentValue, tags.Get("anonymize"), rules); ok {
+					out[outName] = value
+					continue
+				}
 				switch currentValue.Kind() {
 				case reflect.Struct:
 					out[outName] = AnonymizeStruct(currentValue, rules...)
 					continue
-				case reflect.Map:
-					out[outName] = AnonymizeMap(currentValue)
-					continue
-				case reflect.Slice, reflect.Array:
-					for i := 0; i < currentValue.Len(); i++ {
-						out[outName] = AnonymizeStruct(currentValue.Index(i), rules...)
-					}
+				case reflect.Map, reflect.Slice, reflect.Array:
+					out[outName] = anonymizeElement(currentValue, outName, rules)
 					continue
 				}
 				var value any
-				var foundRules bool
+				var foundRules, allowed bool
 				for _, rule := range rules {
-					if rule.Field == outName {
+					allowed = allowed || rule.allows(outName, valueString(currentValue))
+					if rule.applies(outName, valueString(currentValue)) {
 						if ruler, ok := rulerBuiltinLookup[rule.Type]; ok {
 							foundRules = true
-							value = ruler.Replace(currentValue, rule.Value)
+							value = replacedValue(ruler.Replace(currentValue, rule.Value))
 						} else if ruler, ok := rulerCustomLookup[rule.Type]; ok {
 							foundRules = true
-							value = ruler.Replace(currentValue, rule.Value)
+							value = replacedValue(ruler.Replace(currentValue, rule.Value))
 						}
 					}
 				}
-				if !foundRules {
+				if !foundRules && !allowed {
 					tag := tags.Get("anonymize")
 					if tag != "" {
 						anonymizeParts := strings.SplitN(tag, ":", 2)
 						if ruler, ok := rulerBuiltinLookup[anonymizeParts[0]]; ok {
 							if len(anonymizeParts) > 1 {
-								value = ruler.Replace(currentValue, anonymizeParts[1])
+								value = replacedValue(ruler.Replace(currentValue, anonymizeParts[1]))
 							} else if len(anonymizeParts) == 1 {
-								value = ruler.Replace(currentValue, "")
+								value = replacedValue(ruler.Replace(currentValue, ""))
 							}
 						} else if ruler, ok := rulerCustomLookup[anonymizeParts[0]]; ok {
 							if len(anonymizeParts) > 1 {
-								value = ruler.Replace(currentValue, anonymizeParts[1])
+								value = replacedValue(ruler.Replace(currentValue, anonymizeParts[1]))
 							} else if len(anonymizeParts) == 1 {
-								value = ruler.Replace(currentValue, "")
+								value = replacedValue(ruler.Replace(currentValue, ""))
 							}
 						}
 					}
@@ -99,43 +104,55 @@ func AnonymizeMap(val reflect.Value, rules ...Rule) any {
 	case reflect.Map:
 		for _, field := range val.MapKeys() {
 			fieldValue := val.MapIndex(field)
-			var foundMap bool
-			switch fieldValue.Kind() {
-			case reflect.Interface:
-				switch fieldValue.Elem().Kind() {
-				case reflect.Map:
-					switch v := fieldValue.Interface().(type) {
-					case map[string]string, map[string]interface{}:
-						foundMap = true
-						out[field.String()] = AnonymizeMap(reflect.ValueOf(v), rules...)
-					}
-				case reflect.Struct:
-					foundMap = true
-					out[field.String()] = AnonymizeStruct(fieldValue, rules...)
-				}
-			}
-			if !foundMap {
-				var value any
-				for _, rule := range rules {
-					if rule.Field == field.String() {
-						if ruler, ok := rulerBuiltinLookup[rule.Type]; ok {
-							value = ruler.Replace(fieldValue, rule.Value)
-						} else if ruler, ok := rulerCustomLookup[rule.Type]; ok {
-							value = ruler.Replace(fieldValue, rule.Value)
-						}
-					}
-				}
-				if value != nil {
-					out[field.String()] = value
-				} else {
-					out[field.String()] = fieldValue.Interface()
-				}
+			if value, ok := replaceComposite(field.String(), fieldValue, "", rules); ok {
+				out[field.String()] = value
+				continue
 			}
+			out[field.String()] = anonymizeElement(fieldValue, field.String(), rules)
 		}
 	}
 	return out
 }
 
+// anonymizeElement anonymizes a value found under the named field: maps and
+// structs field by field, slices and arrays element by element, the elements
+// taking the name of their field, and other values with the rules of the
+// field.
+func anonymizeElement(val reflect.Value, name string, rules []Rule) any {
+	v := indirect(val)
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Map:
+		return AnonymizeMap(v, rules...)
+	case reflect.Struct:
+		return AnonymizeStruct(v, rules...)
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			items := make([]any, v.Len())
+			for i := range items {
+				items[i] = anonymizeElement(v.Index(i), name, rules)
+			}
+			return items
+		}
+	case reflect.Interface, reflect.Ptr:
+		// A nil value.
+		return v.Interface()
+	}
+	var value any
+	for _, rule := range rules {
+		if rule.applies(name, valueString(v)) {
+			if ruler, ok := lookupReplacer(rule.Type); ok {
+				value = replacedValue(ruler.Replace(v, rule.Value))
+			}
+		}
+	}
+	if value != nil {
+		return value
+	}
+	return v.Interface()
+}
+
 func Anonymize(src any, rules ...Rule) any {
 	switch st := src.(type) {
 	case []byte:
@@ -148,13 +165,7 @@ func Anonymize(src any, rules ...Rule) any {
 	case reflect.Slice, reflect.Array:
 		var responses []any
 		for i := 0; i < source.Len(); i++ {
-			val := source.Index(i)
-			switch val.Kind() {
-			case reflect.Struct:
-				responses = append(responses, AnonymizeStruct(val, rules...))
-			case reflect.Map:
-				responses = append(responses, AnonymizeMap(val, rules...))
-			}
+			responses = append(responses, anonymizeElement(source.Index(i), "", rules))
 		}
 		return responses
 	case reflect.Struct:
@@ -165,27 +176,105 @@ func Anonymize(src any, rules ...Rule) any {
 	return nil
 }
 
+// processBytes anonymizes a JSON document: an object, an array, or a
+// scalar, to which only rules without a field apply.
 func processBytes(data []byte, rules ...Rule) any {
-	var src map[string]any
-	var sources []map[string]any
-	err := json.Unmarshal(data, &src)
-	if err == nil {
-		return AnonymizeMap(reflect.ValueOf(src), rules...)
-	}
-	err = json.Unmarshal(data, &sources)
-	if err == nil {
-		s := reflect.ValueOf(src)
-		var responses []any
-		for i := 0; i < s.Len(); i++ {
-			val := s.Index(i)
-			switch val.Kind() {
-			case reflect.Struct:
-				responses = append(responses, AnonymizeStruct(val, rules...))
-			case reflect.Map:
-				responses = append(responses, AnonymizeMap(val, rules...))
+	var src any
+	if err := json.Unmarshal(data, &src); err != nil {
+		return nil
+	}
+	return anonymizeDocument(reflect.ValueOf(src), rules)
+}
+
+// anonymizeDocument anonymizes a decoded JSON document. The scalars outside
+// any object, such as a scalar document or the elements of a top-level
+// array, take the rules without a field.
+func anonymizeDocument(val reflect.Value, rules []Rule) any {
+	v := indirect(val)
+	switch v.Kind() {
+	case reflect.Slice:
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = anonymizeDocument(v.Index(i), rules)
+		}
+		return items
+	case reflect.Invalid, reflect.Interface, reflect.Map:
+		return anonymizeElement(v, "", rules)
+	}
+	var value any
+	for _, rule := range rules {
+		if rule.appliesToDocument(valueString(v)) {
+			if ruler, ok := lookupReplacer(rule.Type); ok {
+				value = replacedValue(ruler.Replace(v, rule.Value))
 			}
 		}
-		return responses
 	}
-	return nil
+	if value != nil {
+		return value
+	}
+	return v.Interface()
+}
+
+// AnonymizeJSON anonymizes a JSON document as AnonymizeJSONStream does,
+// keeping the order of keys and the text of numbers.
+func AnonymizeJSON(data []byte, rules ...Rule) ([]byte, error) {
+	if !json.Valid(data) {
+		return nil, errors.New("invalid json document")
+	}
+	var buf bytes.Buffer
+	if err := AnonymizeJSONStream(bytes.NewReader(data), &buf, rules...); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// replaceComposite replaces a map, slice or array value as a whole when a
+// rule or the anonymize tag of its field names a replacer handling it, such
+// as Geo for coordinate pairs.
+func replaceComposite(name string, val reflect.Value, tag string, rules []Rule) (any, bool) {
+	for _, rule := range rules {
+		if !rule.applies(name, valueString(val)) {
+			continue
+		}
+		ruler, ok := lookupReplacer(rule.Type)
+		if c, isComposite := ruler.(compositeReplacer); ok && isComposite && c.replacesComposite(val) {
+			return replacedValue(ruler.Replace(val, rule.Value)), true
+		}
+	}
+	if tag != "" {
+		rulerName, param, _ := strings.Cut(tag, ":")
+		ruler, ok := lookupReplacer(rulerName)
+		if c, isComposite := ruler.(compositeReplacer); ok && isComposite && c.replacesComposite(val) {
+			return replacedValue(ruler.Replace(val, param)), true
+		}
+	}
+	return nil, false
+}
+
+// replacedValue returns the value a replacer returned, unwrapping the
+// reflect.Value of a value replaced in place or left untouched.
+func replacedValue(value any) any {
+	rv, ok := value.(reflect.Value)
+	if !ok {
+		return value
+	}
+	if !rv.IsValid() || !rv.CanInterface() {
+		return nil
+	}
+	return rv.Interface()
+}
+
+// valueString returns the text the allow and deny lists of a rule are
+// matched against.
+func valueString(val reflect.Value) string {
+	for val.Kind() == reflect.Interface && !val.IsNil() {
+		val = val.Elem()
+	}
+	switch {
+	case val.Kind() == reflect.String:
+		return val.String()
+	case val.IsValid() && val.CanInterface():
+		return fmt.Sprint(val.Interface())
+	}
+	return ""
 }
@@ -0,0 +1,54 @@
+package anonymizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnonymizeJSON(t *testing.T) {
+	ana := replaceString(&Hasher{}, "ana@example.com", "")
+	tests := []struct {
+		name  string
+		data  string
+		rules []Rule
+		want  string
+	}{
+		{"array", `[{"email":"ana@example.com","id":1}]`, []Rule{{Field: "email", Type: "hash"}}, `[{"email":"` + ana + `","id":1}]`},
+		{"array of ips", `{"ips":["10.1.2.3"]}`, []Rule{{Field: "ips", Type: "ip"}}, `{"ips":["10.1.2.0"]}`},
+		{"nested", `{"b":{"users":[{"email":"ana@example.com"}]},"a":1}`, []Rule{{Field: "b.users.email", Type: "hash"}}, `{"b":{"users":[{"email":"` + ana + `"}]},"a":1}`},
+		{"scalar", `"ana@example.com"`, []Rule{{Type: "hash"}}, `"` + ana + `"`},
+		{"scalars of array", `["ana@example.com",{"email":"bob"}]`, []Rule{{Type: "hash"}}, `["` + ana + `",{"email":"bob"}]`},
+		{"allowed scalar", `"ana@example.com"`, []Rule{{Type: "hash", Allow: &List{Domains: []string{"example.com"}}}}, `"ana@example.com"`},
+		{"scalar without rule", `"ana@example.com"`, []Rule{{Field: "email", Type: "hash"}}, `"ana@example.com"`},
+	}
+	for _, tt := range tests {
+		got, err := AnonymizeJSON([]byte(tt.data), tt.rules...)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%s: got %s, %v, want %s", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := AnonymizeJSON([]byte(`{"a":`)); err == nil {
+		t.Error("AnonymizeJSON of invalid json succeeded")
+	}
+}
+
+func TestAnonymizeBytes(t *testing.T) {
+	ana := replaceString(&Hasher{}, "ana@example.com", "")
+	tests := []struct {
+		name  string
+		data  string
+		rules []Rule
+		want  any
+	}{
+		{"array", `[{"email":"ana@example.com"}]`, []Rule{{Field: "email", Type: "hash"}}, []any{map[string]any{"email": ana}}},
+		{"nested", `{"b":{"users":[{"email":"ana@example.com"}]}}`, []Rule{{Field: "email", Type: "hash"}}, map[string]any{"b": map[string]any{"users": []any{map[string]any{"email": ana}}}}},
+		{"scalar", `"ana@example.com"`, []Rule{{Type: "hash"}}, ana},
+		{"scalars of array", `["ana@example.com",1]`, []Rule{{Type: "hash"}}, []any{ana, replaceString(&Hasher{}, "1", "")}},
+		{"scalar without rule", `"ana@example.com"`, []Rule{{Field: "email", Type: "hash"}}, "ana@example.com"},
+	}
+	for _, tt := range tests {
+		if got := Anonymize(tt.data, tt.rules...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package anonymizer
+
+import "io"
+
+// ConfigSecretDetectors names the detectors of the DefaultRegistry run on
+// the string values of config files without a rule.
+var ConfigSecretDetectors = []string{
+	"rsa_private_key", "dsa_private_key", "ec_private_key", "pgp_private_key", "pem_block",
+	"jwt", "bearer_token", "password_in_url", "api_key", "secret",
+	"aws_access_key", "mws_token", "github_token", "google_api_key", "gcp_oauth", "google_oauth_token",
+	"facebook_token", "facebook_oauth", "heroku_api_key", "mailchimp_api_key", "mailgun_api_key",
+	"braintree_token", "picatic_api_key", "slack_token", "slack_webhook", "stripe_api_key",
+	"stripe_restricted_api_key", "square_access_token", "square_oauth_secret", "twilio_api_key",
+	"twitter_access_token", "twitter_oauth", "cloudinary_url", "firebase_url",
+}
+
+// ConfigOptions configures AnonymizeYAML and AnonymizeTOML.
+type ConfigOptions struct {
+	// Policy redacts the findings of its detectors in the string values
+	// without a rule; a policy running ConfigSecretDetectors when nil.
+	Policy *Policy `json:"policy,omitempty"`
+	// NoDetection leaves the values without a rule untouched.
+	NoDetection bool `json:"no_detection"`
+	// Indent is the indentation of YAML streams AnonymizeYAML encodes from
+	// their node tree; 2 when zero.
+	Indent int `json:"indent"`
+}
+
+func (o ConfigOptions) policy() *Policy {
+	switch {
+	case o.NoDetection:
+		return nil
+	case o.Policy != nil:
+		return o.Policy
+	}
+	return &Policy{Detectors: ConfigSecretDetectors}
+}
+
+// anonymizeConfigValue applies the rules of key or path, a dotted path
+// such as "database.primary.host", to a string value of a config file, or
+// the policy when none applies. Elements of arrays take the key of their
+// array, as in AnonymizeJSONStream.
+func anonymizeConfigValue(value, key, path string, rules []Rule, policy *Policy) (string, bool) {
+	var keyRules []Rule
+	for _, rule := range rules {
+		if rule.Field == "" || rule.Field == key || rule.Field == path {
+			keyRules = append(keyRules, rule)
+		}
+	}
+	return anonymizeCell(value, keyRules, policy)
+}
+
+// configEdit replaces the bytes of data[start:end].
+type configEdit struct {
+	start, end int
+	text       string
+}
+
+// writeEdits writes data with the edits, sorted by start, applied.
+func writeEdits(w io.Writer, data []byte, edits []configEdit) error {
+	last := 0
+	for _, e := range edits {
+		if _, err := w.Write(data[last:e.start]); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, e.text); err != nil {
+			return err
+		}
+		last = e.end
+	}
+	_, err := w.Write(data[last:])
+	return err
+}
@@ -0,0 +1,144 @@
+package anonymizer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Finding is a single piece of sensitive data located in a text.
+type Finding struct {
+	Detector   string  `json:"detector"`
+	Value      string  `json:"value"`
+	Start      int     `json:"start"`
+	End        int     `json:"end"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Context describes the keywords that are expected around a match of an
+// ambiguous pattern. A match starts with Score, gains Boost when one of the
+// Words appears within Window words on either side and loses Penalty otherwise.
+type Context struct {
+	Words   []string `json:"words"`
+	Window  int      `json:"window"`
+	Score   float64  `json:"score"`
+	Boost   float64  `json:"boost"`
+	Penalty float64  `json:"penalty"`
+}
+
+const maxContextWordLen = 32
+
+var (
+	DefaultContextWindow  = 5
+	DefaultContextScore   = 0.5
+	DefaultContextBoost   = 0.35
+	DefaultContextPenalty = 0.1
+
+	// MinConfidence is the threshold below which findings are discarded.
+	// Nothing is discarded by default; set it to DefaultContextScore to drop
+	// the matches of ambiguous patterns without context words around them.
+	MinConfidence = 0.0
+)
+
+var (
+	phoneContextWords = []string{"phone", "telephone", "tel", "mobile", "cell", "call", "contact", "reach", "fax", "whatsapp", "number"}
+	hashContextWords  = []string{"hash", "checksum", "digest", "fingerprint", "sum"}
+)
+
+// ContextMap holds the context words of ambiguous patterns, keyed like RegexMap.
+var ContextMap = map[string]Context{
+	"phone":       {Words: phoneContextWords},
+	"zip_code":    {Words: []string{"zip", "zipcode", "postal", "postcode", "pin", "pincode"}},
+	"coordinates": {Words: []string{"lat", "lng", "lon", "latitude", "longitude", "location", "gps", "coordinates", "geo"}},
+	"ssn":         {Words: []string{"ssn", "social", "security", "ss#", "taxpayer", "tin"}},
+	"md5":         {Words: append([]string{"md5"}, hashContextWords...)},
+	"sha1":        {Words: append([]string{"sha1", "sha-1", "commit"}, hashContextWords...)},
+	"sha256":      {Words: append([]string{"sha256", "sha-256"}, hashContextWords...)},
+}
+
+// Apply adjusts the confidence of the finding according to the words found
+// around it in text.
+func (c Context) Apply(text string, f Finding) Finding {
+	window := c.Window
+	if window <= 0 {
+		window = DefaultContextWindow
+	}
+	f.Confidence = c.Score
+	if f.Confidence == 0 {
+		f.Confidence = DefaultContextScore
+	}
+	if c.hasWord(contextWords(text, f.Start, f.End, window)) {
+		boost := c.Boost
+		if boost == 0 {
+			boost = DefaultContextBoost
+		}
+		f.Confidence += boost
+	} else {
+		penalty := c.Penalty
+		if penalty == 0 {
+			penalty = DefaultContextPenalty
+		}
+		f.Confidence -= penalty
+	}
+	f.Confidence = clampConfidence(f.Confidence)
+	return f
+}
+
+// hasWord reports whether a token, or a part of a token such as "fax" in
+// "phone/fax", is one of the words or its plural. Words match whole tokens
+// only, so "tin" is not found in "time".
+func (c Context) hasWord(tokens []string) bool {
+	for _, token := range tokens {
+		parts := append([]string{token}, strings.FieldsFunc(token, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '#'
+		})...)
+		for _, part := range parts {
+			for _, word := range c.Words {
+				word = strings.ToLower(word)
+				if part == word || part == word+"s" || part == word+"es" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// contextWords returns up to window normalized words on each side of text[start:end].
+func contextWords(text string, start, end, window int) []string {
+	from := start - window*maxContextWordLen
+	if from < 0 {
+		from = 0
+	}
+	to := end + window*maxContextWordLen
+	if to > len(text) {
+		to = len(text)
+	}
+	before := strings.Fields(text[from:start])
+	if len(before) > window {
+		before = before[len(before)-window:]
+	}
+	after := strings.Fields(text[end:to])
+	if len(after) > window {
+		after = after[:window]
+	}
+	tokens := make([]string, 0, len(before)+len(after))
+	for _, field := range append(before, after...) {
+		token := strings.ToLower(strings.TrimFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '#'
+		}))
+		if token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+func clampConfidence(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
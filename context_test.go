@@ -0,0 +1,96 @@
+package anonymizer
+
+import "testing"
+
+func TestContextApply(t *testing.T) {
+	ctx := Context{Words: []string{"tin", "sum", "pin", "phone", "ss#"}}
+	tests := []struct {
+		text  string
+		boost bool
+	}{
+		{"my tin is 123", true},
+		{"TIN: 123", true},
+		{"at this time 123", false},
+		{"summary 123", false},
+		{"ping 123", false},
+		{"phones 123", true},
+		{"phone/fax 123", true},
+		{"ss# 123", true},
+		{"123 and the pin", true},
+	}
+	for _, tt := range tests {
+		start := len(tt.text) - 3
+		if tt.text[0] == '1' {
+			start = 0
+		}
+		f := ctx.Apply(tt.text, Finding{Value: "123", Start: start, End: start + 3})
+		if boosted := f.Confidence > DefaultContextScore; boosted != tt.boost {
+			t.Errorf("Apply(%q) confidence = %v, boosted %v, want %v", tt.text, f.Confidence, boosted, tt.boost)
+		}
+	}
+}
+
+func TestContextWordsWindow(t *testing.T) {
+	got := contextWords("a b c d e f 123 g h", 12, 15, 2)
+	want := []string{"e", "f", "g", "h"}
+	if len(got) != len(want) {
+		t.Fatalf("contextWords = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("contextWords = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestFindAllContext(t *testing.T) {
+	defer func(old float64) { MinConfidence = old }(MinConfidence)
+	MinConfidence = DefaultContextScore
+	tests := []struct {
+		text, detector string
+		found          bool
+	}{
+		{"my ssn is 078-05-1120", "ssn", true},
+		{"the tin is 078-05-1120", "ssn", true},
+		{"at this time 078-05-1120", "ssn", false},
+		{"summary d41d8cd98f00b204e9800998ecf8427e", "md5", false},
+		{"md5 checksum d41d8cd98f00b204e9800998ecf8427e", "md5", true},
+	}
+	for _, tt := range tests {
+		found := false
+		for _, f := range FindAll(tt.text, tt.detector) {
+			found = found || f.Detector == tt.detector
+		}
+		if found != tt.found {
+			t.Errorf("FindAll(%q, %q) found %v, want %v", tt.text, tt.detector, found, tt.found)
+		}
+	}
+}
+
+func TestFindAllWithoutContext(t *testing.T) {
+	tests := []struct {
+		text, detector string
+	}{
+		{"078-05-1120", "ssn"},
+		{"at this time 078-05-1120", "ssn"},
+		{"555-123-4567", "phone"},
+		{"d41d8cd98f00b204e9800998ecf8427e", "md5"},
+	}
+	for _, tt := range tests {
+		found := false
+		for _, f := range FindAll(tt.text, tt.detector) {
+			if f.Detector == tt.detector {
+				found = true
+				if f.Confidence >= DefaultContextScore {
+					t.Errorf("FindAll(%q) confidence = %v, want below %v", tt.text, f.Confidence, DefaultContextScore)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("FindAll(%q, %q) found nothing", tt.text, tt.detector)
+		}
+		if values := ParseMultiple(tt.text)[tt.detector]; len(values) == 0 {
+			t.Errorf("ParseMultiple(%q) has no %s", tt.text, tt.detector)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package anonymizer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const utf8BOM = "\xef\xbb\xbf"
+
+// CSVOptions configures AnonymizeCSV.
+type CSVOptions struct {
+	// Comma is the field delimiter; ',' when zero.
+	Comma rune `json:"comma"`
+	// NoHeader tells the first row holds data. Rules then name columns by
+	// their 0-based index only.
+	NoHeader bool `json:"no_header"`
+	// Policy, when set, redacts the findings of its detectors in the cells
+	// of columns without a rule.
+	Policy *Policy `json:"policy,omitempty"`
+}
+
+// csvField is a field of a record as read, and as decoded.
+type csvField struct {
+	raw    string
+	value  string
+	quoted bool
+}
+
+// AnonymizeCSV anonymizes a CSV document row by row. Rules pick the columns
+// they apply to through Rule.Field, a header name or a 0-based column index;
+// rules without a field apply to the values of their deny list in every
+// column. Empty cells are kept. Rows, untouched cells, quoting, line endings
+// and a leading byte order mark are written back as read.
+func AnonymizeCSV(r io.Reader, w io.Writer, rules []Rule, opts CSVOptions) error {
+	comma := string(opts.Comma)
+	if opts.Comma == 0 {
+		comma = ","
+	}
+	reader, writer := bufio.NewReader(r), bufio.NewWriter(w)
+	var columns [][]Rule
+	for line := 1; ; line++ {
+		record, terminator, err := readCSVRecord(reader)
+		if err == io.EOF && record == "" {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line == 1 && strings.HasPrefix(record, utf8BOM) {
+			writer.WriteString(utf8BOM)
+			record = record[len(utf8BOM):]
+		}
+		fields, err := splitCSVRecord(record, comma)
+		if err != nil {
+			return fmt.Errorf("csv line %d: %w", line, err)
+		}
+		if line == 1 && !opts.NoHeader {
+			for i := range fields {
+				columns = append(columns, csvColumnRules(i, &fields[i], rules))
+			}
+			writer.WriteString(record + terminator)
+			continue
+		}
+		// Columns past the header, or past the widest row so far without
+		// one, are named by their index only.
+		for len(columns) < len(fields) {
+			columns = append(columns, csvColumnRules(len(columns), nil, rules))
+		}
+		for i, field := range fields {
+			if i > 0 {
+				writer.WriteString(comma)
+			}
+			value, changed := anonymizeCell(field.value, columns[i], opts.Policy)
+			if !changed {
+				writer.WriteString(field.raw)
+				continue
+			}
+			writer.WriteString(encodeCSVField(value, comma, field.quoted))
+		}
+		writer.WriteString(terminator)
+	}
+	return writer.Flush()
+}
+
+// csvColumnRules returns the rules of the column at index i, with its
+// header cell when it has one.
+func csvColumnRules(i int, header *csvField, rules []Rule) []Rule {
+	var column []Rule
+	for _, rule := range rules {
+		if rule.Field == "" || rule.Field == strconv.Itoa(i) || (header != nil && rule.Field == header.value) {
+			column = append(column, rule)
+		}
+	}
+	return column
+}
+
+// anonymizeCell applies the rules of a column to a cell, or the policy when
+// the column has no rule applying to the cell.
+func anonymizeCell(value string, rules []Rule, policy *Policy) (string, bool) {
+	if value == "" {
+		return value, false
+	}
+	var replaced *string
+	for _, rule := range rules {
+		if !rule.applies(rule.Field, value) {
+			continue
+		}
+		if ruler, ok := lookupReplacer(rule.Type); ok {
+			v := replaceString(ruler, value, rule.Value)
+			replaced = &v
+		}
+	}
+	if replaced != nil {
+		return *replaced, *replaced != value
+	}
+	if policy != nil {
+		redacted := Redact(value, *policy)
+		return redacted, redacted != value
+	}
+	return value, false
+}
+
+// readCSVRecord reads a record, which spans lines when a quoted field holds
+// line breaks, and returns it without its line terminator.
+func readCSVRecord(reader *bufio.Reader) (string, string, error) {
+	var b strings.Builder
+	quotes := 0
+	for {
+		line, err := reader.ReadString('\n')
+		b.WriteString(line)
+		if quotes += strings.Count(line, `"`); err != nil || quotes%2 == 0 {
+			record := b.String()
+			terminator := ""
+			if strings.HasSuffix(record, "\n") {
+				terminator = "\n"
+				if strings.HasSuffix(record, "\r\n") {
+					terminator = "\r\n"
+				}
+			}
+			return strings.TrimSuffix(record, terminator), terminator, err
+		}
+	}
+}
+
+// splitCSVRecord splits a record into its fields.
+func splitCSVRecord(record, comma string) ([]csvField, error) {
+	var fields []csvField
+	start, inQuotes := 0, false
+	for i := 0; i <= len(record); i++ {
+		if i < len(record) && record[i] == '"' {
+			inQuotes = !inQuotes
+			continue
+		}
+		if inQuotes || (i < len(record) && !strings.HasPrefix(record[i:], comma)) {
+			continue
+		}
+		raw := record[start:i]
+		field := csvField{raw: raw, value: raw}
+		if trimmed := strings.TrimSpace(raw); strings.HasPrefix(trimmed, `"`) {
+			if len(trimmed) < 2 || !strings.HasSuffix(trimmed, `"`) {
+				return nil, fmt.Errorf("bare %q in quoted field %q", `"`, raw)
+			}
+			field.value = strings.ReplaceAll(trimmed[1:len(trimmed)-1], `""`, `"`)
+			field.quoted = true
+		}
+		fields = append(fields, field)
+		start = i + len(comma)
+		i = start - 1
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quoted field")
+	}
+	return fields, nil
+}
+
+// encodeCSVField quotes a field when it was quoted or needs to be.
+func encodeCSVField(value, comma string, quoted bool) string {
+	if quoted || strings.Contains(value, comma) || strings.ContainsAny(value, "\"\r\n") || strings.HasPrefix(value, " ") {
+		return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+	}
+	return value
+}
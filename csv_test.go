@@ -0,0 +1,121 @@
+package anonymizer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAnonymizeCSV(t *testing.T) {
+	ana := replaceString(&Hasher{}, "ana@example.com", "")
+	tests := []struct {
+		name  string
+		data  string
+		rules []Rule
+		opts  CSVOptions
+		want  string
+	}{
+		{
+			"header names and indexes",
+			"id,email,name\n1,ana@example.com,Ana\n2,,Bob\n",
+			[]Rule{{Field: "email", Type: "hash"}, {Field: "2", Type: "asterisk"}},
+			CSVOptions{},
+			"id,email,name\n1," + ana + ",***\n2,,***\n",
+		},
+		{
+			"untouched cells are written as read",
+			"id,\"note\",email\r\n 1 ,\"a \"\"b\"\"\",\"ana@example.com\"\r\n",
+			[]Rule{{Field: "email", Type: "hash"}},
+			CSVOptions{},
+			"id,\"note\",email\r\n 1 ,\"a \"\"b\"\"\",\"" + ana + "\"\r\n",
+		},
+		{
+			"replaced values are quoted when needed",
+			"id,city\n1,Kathmandu\n2,\"Pokhara\"\n",
+			[]Rule{{Field: "city", Type: "template", Value: `<c:/\w+/>;c=asterisk`}, {Field: "id", Type: "empty"}},
+			CSVOptions{},
+			"id,city\n,*********\n,\"*******\"\n",
+		},
+		{
+			"byte order mark and quoted line breaks",
+			utf8BOM + "note,email\n\"line 1\nline 2\",ana@example.com\n",
+			[]Rule{{Field: "email", Type: "hash"}, {Field: "note", Type: "asterisk"}},
+			CSVOptions{},
+			utf8BOM + "note,email\n\"*************\"," + ana + "\n",
+		},
+		{
+			"delimiter and no header",
+			"1;ana@example.com;x\n2;bob;y",
+			[]Rule{{Field: "1", Type: "asterisk"}},
+			CSVOptions{Comma: ';', NoHeader: true},
+			"1;***************;x\n2;***;y",
+		},
+		{
+			"ragged rows",
+			"1,a\n2,b,secret\n3\n",
+			[]Rule{{Field: "2", Type: "asterisk"}},
+			CSVOptions{NoHeader: true},
+			"1,a\n2,b,******\n3\n",
+		},
+		{
+			"columns past the header",
+			"id,name\n1,Ana,secret\n",
+			[]Rule{{Field: "name", Type: "asterisk"}, {Field: "2", Type: "asterisk"}},
+			CSVOptions{},
+			"id,name\n1,***,******\n",
+		},
+		{
+			"deny list in every column",
+			"a,b\nroot,x\nx,root\n",
+			[]Rule{{Type: "asterisk", Deny: &List{Values: []string{"root"}}}},
+			CSVOptions{},
+			"a,b\n****,x\nx,****\n",
+		},
+		{
+			"policy for columns without rule",
+			"id,comment\n7,\"mail ana@example.com, thanks\"\n",
+			[]Rule{{Field: "id", Type: "asterisk"}},
+			CSVOptions{Policy: &Policy{Detectors: []string{"email"}}},
+			"id,comment\n*,\"mail [EMAIL], thanks\"\n",
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := AnonymizeCSV(strings.NewReader(tt.data), &buf, tt.rules, tt.opts); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAnonymizeCSVErrors(t *testing.T) {
+	for _, data := range []string{"a,b\n1,\"x\n", "a,b\n1,\"x\"y\"\n"} {
+		err := AnonymizeCSV(strings.NewReader(data), &bytes.Buffer{}, nil, CSVOptions{})
+		if err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("AnonymizeCSV(%q) = %v, want an error on line 2", data, err)
+		}
+	}
+}
+
+func TestEncodeCSVField(t *testing.T) {
+	tests := []struct {
+		value  string
+		quoted bool
+		want   string
+	}{
+		{"plain", false, "plain"},
+		{"plain", true, `"plain"`},
+		{"a,b", false, `"a,b"`},
+		{`say "hi"`, false, `"say ""hi"""`},
+		{"two\nlines", false, "\"two\nlines\""},
+		{" lead", false, `" lead"`},
+	}
+	for _, tt := range tests {
+		if got := encodeCSVField(tt.value, ",", tt.quoted); got != tt.want {
+			t.Errorf("encodeCSVField(%q, %v) = %q, want %q", tt.value, tt.quoted, got, tt.want)
+		}
+	}
+}
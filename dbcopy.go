@@ -0,0 +1,368 @@
+package anonymizer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CopyTable is a table copied by a CopyJob.
+type CopyTable struct {
+	// Name of the table in the source, qualified by its schema or not.
+	Name string `json:"name"`
+	// Target is the name of the table in the target; Name when empty.
+	Target string `json:"target"`
+	// Key holds the columns of a unique key, such as the primary key, the
+	// rows are read in the order of.
+	Key []string `json:"key"`
+	// Columns copied; all the columns of the source table when empty.
+	Columns []string `json:"columns"`
+	// Rules apply to the columns named by Rule.Field, after the job's rules.
+	Rules []Rule `json:"rules"`
+}
+
+// CopyJob copies tables from a database to another through database/sql,
+// anonymizing their rows on the way. Each table is read in batches with
+// keyset pagination, so reads stay cheap deep into large tables, and each
+// batch is written with multi-row inserts in a transaction of its own.
+//
+// A value replaced by the same replacer with the same parameter gets the
+// same replacement in every table, as in AnonymizeSQLDump.
+type CopyJob struct {
+	Source *sql.DB `json:"-"`
+	Target *sql.DB `json:"-"`
+	// SourceDialect and TargetDialect are "postgres", "mysql" or "sqlite",
+	// which set the placeholders and the quoting of identifiers; "sqlite"
+	// when empty.
+	SourceDialect string      `json:"source_dialect"`
+	TargetDialect string      `json:"target_dialect"`
+	Tables        []CopyTable `json:"tables"`
+	// Rules apply to the columns named by Rule.Field, a column name or a
+	// column qualified by its table such as "users.email".
+	Rules []Rule `json:"rules"`
+	// BatchSize is the number of rows read and written at once; 1000 when zero.
+	BatchSize int `json:"batch_size"`
+	// Parallel is the number of tables copied at once; 1 when zero.
+	Parallel int `json:"parallel"`
+	// Checkpoints, when set, names a table of the target the job saves the
+	// progress of each table to, in the transaction of each batch. Running
+	// the job again then resumes each table after its last batch written,
+	// and skips the tables copied.
+	Checkpoints string `json:"checkpoints"`
+}
+
+type copyDialect struct {
+	quote     string
+	numbered  bool
+	maxParams int
+}
+
+var copyDialects = map[string]copyDialect{
+	"postgres": {quote: `"`, numbered: true, maxParams: 65535},
+	"mysql":    {quote: "`", maxParams: 65535},
+	"sqlite":   {quote: `"`, maxParams: 32766},
+}
+
+func getCopyDialect(name string) (copyDialect, error) {
+	if name == "" {
+		name = "sqlite"
+	}
+	d, ok := copyDialects[name]
+	if !ok {
+		return d, fmt.Errorf("dialect %q is not exists", name)
+	}
+	return d, nil
+}
+
+// ident quotes a name, qualified by its schema or not.
+func (d copyDialect) ident(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = d.quote + strings.ReplaceAll(part, d.quote, d.quote+d.quote) + d.quote
+	}
+	return strings.Join(parts, ".")
+}
+
+func (d copyDialect) idents(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = d.ident(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// params returns n placeholders, numbered from from + 1.
+func (d copyDialect) params(from, n int) string {
+	params := make([]string, n)
+	for i := range params {
+		params[i] = "?"
+		if d.numbered {
+			params[i] = "$" + strconv.Itoa(from+i+1)
+		}
+	}
+	return strings.Join(params, ", ")
+}
+
+// Run copies the tables of the job. Tables failing do not stop the others;
+// their errors are returned together.
+func (j *CopyJob) Run(ctx context.Context) error {
+	if j.Source == nil || j.Target == nil {
+		return errors.New("source or target database is null")
+	}
+	source, err := getCopyDialect(j.SourceDialect)
+	if err != nil {
+		return err
+	}
+	target, err := getCopyDialect(j.TargetDialect)
+	if err != nil {
+		return err
+	}
+	if j.Checkpoints != "" {
+		_, err := j.Target.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+target.ident(j.Checkpoints)+
+			" (table_name VARCHAR(255) NOT NULL PRIMARY KEY, last_key TEXT, done INTEGER NOT NULL)")
+		if err != nil {
+			return err
+		}
+	}
+	c := &tableCopier{job: j, source: source, target: target, pseudonyms: newPseudonymCache()}
+	errs := make([]error, len(j.Tables))
+	sem := make(chan struct{}, max(j.Parallel, 1))
+	var wg sync.WaitGroup
+	for i, table := range j.Tables {
+		wg.Add(1)
+		go func(i int, table CopyTable) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if err := c.copy(ctx, table); err != nil {
+				errs[i] = fmt.Errorf("copy %s: %w", table.Name, err)
+			}
+		}(i, table)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+type tableCopier struct {
+	job            *CopyJob
+	source, target copyDialect
+	pseudonyms     *pseudonymCache
+}
+
+func (c *tableCopier) copy(ctx context.Context, table CopyTable) error {
+	if len(table.Key) == 0 {
+		return errors.New("key is null")
+	}
+	columns := table.Columns
+	if len(columns) == 0 {
+		var err error
+		if columns, err = c.columns(ctx, table.Name); err != nil {
+			return err
+		}
+	}
+	keys := make([]int, len(table.Key))
+	for i, key := range table.Key {
+		keys[i] = -1
+		for k, column := range columns {
+			if strings.EqualFold(key, column) {
+				keys[i] = k
+			}
+		}
+		if keys[i] < 0 {
+			return fmt.Errorf("key column %q is not copied", key)
+		}
+	}
+	rules := append(append([]Rule(nil), c.job.Rules...), table.Rules...)
+	columnRules := sqlColumnRules(rules, strings.ToLower(table.Name), columns)
+	last, done, err := c.checkpoint(ctx, table.Name)
+	if err != nil || done {
+		return err
+	}
+	batchSize := c.job.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	for {
+		rows, err := c.read(ctx, table, columns, last, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			// The key is read before its columns may be anonymized.
+			last = make([]any, len(keys))
+			for i, k := range keys {
+				last[i] = rows[len(rows)-1][k]
+			}
+		}
+		for _, row := range rows {
+			c.anonymize(row, columnRules)
+		}
+		target := table.Target
+		if target == "" {
+			target = table.Name
+		}
+		done := len(rows) < batchSize
+		if err := c.write(ctx, table.Name, target, columns, rows, last, done); err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// columns returns the columns of a table of the source.
+func (c *tableCopier) columns(ctx context.Context, table string) ([]string, error) {
+	rows, err := c.job.Source.QueryContext(ctx, "SELECT * FROM "+c.source.ident(table)+" WHERE 1 = 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
+
+// read reads the rows following the key last, in the order of the key.
+func (c *tableCopier) read(ctx context.Context, table CopyTable, columns []string, last []any, n int) ([][]any, error) {
+	query := "SELECT " + c.source.idents(columns) + " FROM " + c.source.ident(table.Name)
+	if last != nil {
+		query += " WHERE (" + c.source.idents(table.Key) + ") > (" + c.source.params(0, len(last)) + ")"
+	}
+	query += " ORDER BY " + c.source.idents(table.Key) + " LIMIT " + strconv.Itoa(n)
+	rows, err := c.job.Source.QueryContext(ctx, query, last...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var batch [][]any
+	for rows.Next() {
+		row := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		batch = append(batch, row)
+	}
+	return batch, rows.Err()
+}
+
+// anonymize applies the rules of each column to the cells of a row. NULLs
+// are kept.
+func (c *tableCopier) anonymize(row []any, columnRules [][]Rule) {
+	for i, cell := range row {
+		if cell == nil || len(columnRules[i]) == 0 {
+			continue
+		}
+		var value string
+		switch v := cell.(type) {
+		case []byte:
+			value = string(v)
+		case time.Time:
+			value = v.Format(time.RFC3339Nano)
+		default:
+			value = fmt.Sprint(v)
+		}
+		if replaced, changed := c.pseudonyms.replace(value, columnRules[i]); changed {
+			row[i] = replaced
+		}
+	}
+}
+
+// write inserts rows into the target and saves the checkpoint of the table
+// in the same transaction.
+func (c *tableCopier) write(ctx context.Context, table, target string, columns []string, rows [][]any, last []any, done bool) error {
+	tx, err := c.job.Target.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	perInsert := max(c.target.maxParams/len(columns), 1)
+	for start := 0; start < len(rows); start += perInsert {
+		chunk := rows[start:min(start+perInsert, len(rows))]
+		values := make([]string, len(chunk))
+		var args []any
+		for i, row := range chunk {
+			values[i] = "(" + c.target.params(len(args), len(row)) + ")"
+			args = append(args, row...)
+		}
+		query := "INSERT INTO " + c.target.ident(target) + " (" + c.target.idents(columns) + ") VALUES " + strings.Join(values, ", ")
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	if err := c.saveCheckpoint(ctx, tx, table, last, done); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkpoint returns the key of the last row copied of a table, and
+// whether it is copied.
+func (c *tableCopier) checkpoint(ctx context.Context, table string) ([]any, bool, error) {
+	if c.job.Checkpoints == "" {
+		return nil, false, nil
+	}
+	var lastKey sql.NullString
+	var done int
+	err := c.job.Target.QueryRowContext(ctx, "SELECT last_key, done FROM "+c.target.ident(c.job.Checkpoints)+
+		" WHERE table_name = "+c.target.params(0, 1), table).Scan(&lastKey, &done)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil || !lastKey.Valid {
+		return nil, done != 0, err
+	}
+	dec := json.NewDecoder(strings.NewReader(lastKey.String))
+	dec.UseNumber()
+	var last []any
+	if err := dec.Decode(&last); err != nil {
+		return nil, false, fmt.Errorf("checkpoint: %w", err)
+	}
+	for i, v := range last {
+		if n, ok := v.(json.Number); ok {
+			if last[i], err = n.Int64(); err != nil {
+				last[i], _ = n.Float64()
+			}
+		}
+	}
+	return last, done != 0, nil
+}
+
+func (c *tableCopier) saveCheckpoint(ctx context.Context, tx *sql.Tx, table string, last []any, done bool) error {
+	if c.job.Checkpoints == "" {
+		return nil
+	}
+	var lastKey sql.NullString
+	if last != nil {
+		key := make([]any, len(last))
+		for i, v := range last {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			key[i] = v
+		}
+		data, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		lastKey = sql.NullString{String: string(data), Valid: true}
+	}
+	checkpoints := c.target.ident(c.job.Checkpoints)
+	if _, err := tx.ExecContext(ctx, "DELETE FROM "+checkpoints+" WHERE table_name = "+c.target.params(0, 1), table); err != nil {
+		return err
+	}
+	doneValue := 0
+	if done {
+		doneValue = 1
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO "+checkpoints+" (table_name, last_key, done) VALUES ("+c.target.params(0, 3)+")", table, lastKey, doneValue)
+	return err
+}
@@ -0,0 +1,248 @@
+package anonymizer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// openSQLite opens a SQLite database in the test's directory.
+func openSQLite(t *testing.T, name string, stmts ...string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), name)+"?_pragma=busy_timeout(10000)&_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Skipf("sqlite: %v", err)
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	return db
+}
+
+// insertRows inserts n rows built by row into table.
+func insertRows(t *testing.T, db *sql.DB, table string, n int, row func(i int) []any) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		args := row(i)
+		query := "INSERT INTO " + table + " VALUES (" + strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ") + ")"
+		if _, err := db.Exec(query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// queryRows returns the rows of query as strings.
+func queryRows(t *testing.T, db *sql.DB, query string) [][]string {
+	t.Helper()
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	columns, _ := rows.Columns()
+	var all [][]string
+	for rows.Next() {
+		cells := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range cells {
+			dest[i] = &cells[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			t.Fatal(err)
+		}
+		row := make([]string, len(cells))
+		for i, cell := range cells {
+			if b, ok := cell.([]byte); ok {
+				cell = string(b)
+			}
+			row[i] = fmt.Sprint(cell)
+		}
+		all = append(all, row)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return all
+}
+
+func TestCopyJobBatches(t *testing.T) {
+	source := openSQLite(t, "source.db",
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT, note TEXT)",
+		"CREATE TABLE events (day TEXT, seq INTEGER, what TEXT, PRIMARY KEY (day, seq))")
+	target := openSQLite(t, "target.db",
+		"CREATE TABLE users_copy (id INTEGER PRIMARY KEY, email TEXT, note TEXT)",
+		"CREATE TABLE events (day TEXT, seq INTEGER, what TEXT, PRIMARY KEY (day, seq))")
+	insertRows(t, source, "users", 25, func(i int) []any {
+		var note any
+		if i%5 != 0 {
+			note = fmt.Sprintf("note %d", i)
+		}
+		return []any{i, fmt.Sprintf("user%d@example.com", i), note}
+	})
+	insertRows(t, source, "events", 9, func(i int) []any {
+		return []any{fmt.Sprintf("2024-01-0%d", (i+1)/2), i % 2, fmt.Sprintf("event %d", i)}
+	})
+
+	job := &CopyJob{
+		Source:    source,
+		Target:    target,
+		BatchSize: 4,
+		Tables: []CopyTable{
+			{Name: "users", Target: "users_copy", Key: []string{"id"}},
+			{Name: "events", Key: []string{"day", "seq"}, Columns: []string{"day", "seq", "what"}},
+		},
+		Rules: []Rule{{Field: "email", Type: "hash"}, {Field: "events.what", Type: "asterisk"}},
+	}
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	users := queryRows(t, target, "SELECT id, email, note FROM users_copy ORDER BY id")
+	if len(users) != 25 {
+		t.Fatalf("copied %d users, want 25", len(users))
+	}
+	for i, row := range users {
+		id := i + 1
+		want := []string{fmt.Sprint(id), replaceString(&Hasher{}, fmt.Sprintf("user%d@example.com", id), ""), fmt.Sprintf("note %d", id)}
+		if id%5 == 0 {
+			want[2] = "<nil>"
+		}
+		if !reflect.DeepEqual(row, want) {
+			t.Errorf("user %d = %q, want %q", id, row, want)
+		}
+	}
+	events := queryRows(t, target, "SELECT day, seq, what FROM events ORDER BY day, seq")
+	if len(events) != 9 {
+		t.Fatalf("copied %d events, want 9", len(events))
+	}
+	for _, row := range events {
+		if strings.Trim(row[2], "*") != "" {
+			t.Errorf("event %q is not anonymized", row)
+		}
+	}
+}
+
+func TestCopyJobResume(t *testing.T) {
+	source := openSQLite(t, "source.db", "CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT)")
+	target := openSQLite(t, "target.db",
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT)",
+		"CREATE TRIGGER fail BEFORE INSERT ON users WHEN NEW.id = 17 BEGIN SELECT RAISE(ABORT, 'boom'); END")
+	insertRows(t, source, "users", 25, func(i int) []any {
+		return []any{i, fmt.Sprintf("user%d@example.com", i)}
+	})
+	job := &CopyJob{
+		Source:      source,
+		Target:      target,
+		BatchSize:   10,
+		Tables:      []CopyTable{{Name: "users", Key: []string{"id"}}},
+		Rules:       []Rule{{Field: "email", Type: "hash"}},
+		Checkpoints: "copy_checkpoints",
+	}
+	if err := job.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("Run = %v, want the error of the failed batch", err)
+	}
+	if got := queryRows(t, target, "SELECT COUNT(*), MAX(id) FROM users"); !reflect.DeepEqual(got, [][]string{{"10", "10"}}) {
+		t.Fatalf("rows after the failed batch = %q, want the first batch", got)
+	}
+	if got := queryRows(t, target, "SELECT table_name, last_key, done FROM copy_checkpoints"); !reflect.DeepEqual(got, [][]string{{"users", "[10]", "0"}}) {
+		t.Fatalf("checkpoints = %q", got)
+	}
+
+	if _, err := target.Exec("DROP TRIGGER fail"); err != nil {
+		t.Fatal(err)
+	}
+	// The primary key of the target fails the run if a row is copied twice.
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := queryRows(t, target, "SELECT COUNT(*), MIN(id), MAX(id) FROM users"); !reflect.DeepEqual(got, [][]string{{"25", "1", "25"}}) {
+		t.Fatalf("rows after resuming = %q", got)
+	}
+	if got := queryRows(t, target, "SELECT done FROM copy_checkpoints WHERE table_name = 'users'"); !reflect.DeepEqual(got, [][]string{{"1"}}) {
+		t.Fatalf("checkpoint after resuming = %q", got)
+	}
+	// A copied table is skipped.
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCopyJobParallelPseudonyms(t *testing.T) {
+	// The replacer numbers the values it sees, so equal replacements come
+	// from the pseudonyms shared by the tables.
+	if err := AddCustomReplacer("sequence", &sequenceReplacer{}); err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveCustomReplacer("sequence")
+
+	source := openSQLite(t, "source.db",
+		"CREATE TABLE customers (id INTEGER PRIMARY KEY, email TEXT)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT)",
+		"CREATE TABLE invoices (id INTEGER PRIMARY KEY, billed_to TEXT)")
+	target := openSQLite(t, "target.db",
+		"CREATE TABLE customers (id INTEGER PRIMARY KEY, email TEXT)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT)",
+		"CREATE TABLE invoices (id INTEGER PRIMARY KEY, billed_to TEXT)")
+	email := func(i int) string { return fmt.Sprintf("c%d@example.com", i%7) }
+	insertRows(t, source, "customers", 7, func(i int) []any { return []any{i, email(i)} })
+	insertRows(t, source, "orders", 40, func(i int) []any { return []any{i, email(i)} })
+	insertRows(t, source, "invoices", 40, func(i int) []any { return []any{i, email(i * 3)} })
+
+	job := &CopyJob{
+		Source:    source,
+		Target:    target,
+		BatchSize: 5,
+		Parallel:  3,
+		Tables: []CopyTable{
+			{Name: "customers", Key: []string{"id"}},
+			{Name: "orders", Key: []string{"id"}},
+			{Name: "invoices", Key: []string{"id"}, Rules: []Rule{{Field: "billed_to", Type: "sequence"}}},
+		},
+		Rules: []Rule{{Field: "customers.email", Type: "sequence"}, {Field: "orders.customer", Type: "sequence"}},
+	}
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	pseudonyms := map[string]string{}
+	check := func(table, column string, value func(i int) string) {
+		for _, row := range queryRows(t, target, "SELECT id, "+column+" FROM "+table+" ORDER BY id") {
+			var id int
+			fmt.Sscan(row[0], &id)
+			original := value(id)
+			if row[1] == original || !strings.HasPrefix(row[1], "customer-") {
+				t.Errorf("%s %d: %q is not replaced", table, id, row[1])
+			}
+			if p, ok := pseudonyms[original]; ok && p != row[1] {
+				t.Errorf("%s %d: %q is replaced by %q and %q", table, id, original, p, row[1])
+			}
+			pseudonyms[original] = row[1]
+		}
+	}
+	check("customers", "email", email)
+	check("orders", "customer", email)
+	check("invoices", "billed_to", func(i int) string { return email(i * 3) })
+	if len(pseudonyms) != 7 {
+		t.Errorf("%d pseudonyms, want 7", len(pseudonyms))
+	}
+}
+
+// sequenceReplacer replaces values by a new number each time.
+type sequenceReplacer struct{ n atomic.Int64 }
+
+func (r *sequenceReplacer) Replace(source any, name string) any {
+	return fmt.Sprintf("customer-%d", r.n.Add(1))
+}
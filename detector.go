@@ -0,0 +1,557 @@
+package anonymizer
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Detector locates one kind of sensitive data in a text.
+type Detector interface {
+	Name() string
+	Find(text string) []Finding
+}
+
+// RegexDetector reports every match of a regular expression, or of its
+// capture group Group, accepted by Validate, optionally scored by the words
+// around it. Anchors are literals one of which occurs in every match; see
+// Scanner.
+type RegexDetector struct {
+	name     string
+	Regex    *regexp.Regexp
+	Group    int
+	Context  *Context
+	Anchors  []string
+	Validate func(value string) bool
+}
+
+func NewRegexDetector(name string, regex *regexp.Regexp) *RegexDetector {
+	return &RegexDetector{name: name, Regex: regex}
+}
+
+// WithContext attaches context words to the detector and returns it.
+func (d *RegexDetector) WithContext(ctx Context) *RegexDetector {
+	d.Context = &ctx
+	return d
+}
+
+// WithAnchors sets the literals required in every match and returns the detector.
+func (d *RegexDetector) WithAnchors(anchors ...string) *RegexDetector {
+	d.Anchors = anchors
+	return d
+}
+
+// WithGroup makes the detector report a capture group instead of the whole
+// match and returns it.
+func (d *RegexDetector) WithGroup(group int) *RegexDetector {
+	d.Group = group
+	return d
+}
+
+// WithValidator sets the check a match must pass and returns the detector.
+func (d *RegexDetector) WithValidator(validate func(value string) bool) *RegexDetector {
+	d.Validate = validate
+	return d
+}
+
+func (d *RegexDetector) Name() string {
+	return d.name
+}
+
+func (d *RegexDetector) Find(text string) []Finding {
+	findings := d.match(text)
+	if d.Context != nil {
+		for i := range findings {
+			findings[i] = d.Context.Apply(text, findings[i])
+		}
+	}
+	return findings
+}
+
+// MaxLen returns the longest match in bytes the detector can report, or -1
+// when its pattern is unbounded.
+func (d *RegexDetector) MaxLen() int {
+	return maxLenOf(d.Regex.String())
+}
+
+// match returns the validated matches without context scoring.
+func (d *RegexDetector) match(text string) []Finding {
+	var findings []Finding
+	if d.Group > 0 {
+		for _, loc := range d.Regex.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[2*d.Group], loc[2*d.Group+1]
+			if start < 0 {
+				continue
+			}
+			findings = append(findings, Finding{
+				Detector:   d.name,
+				Value:      text[start:end],
+				Start:      start,
+				End:        end,
+				Confidence: 1,
+			})
+		}
+	} else {
+		findings = regexFindings(text, d.name, d.Regex)
+	}
+	if d.Validate == nil {
+		return findings
+	}
+	valid := findings[:0]
+	for _, f := range findings {
+		if d.Validate(f.Value) {
+			valid = append(valid, f)
+		}
+	}
+	return valid
+}
+
+// FuncDetector wraps a plain function as a Detector.
+type FuncDetector struct {
+	name string
+	fn   func(text string) []Finding
+}
+
+func NewFuncDetector(name string, fn func(text string) []Finding) *FuncDetector {
+	return &FuncDetector{name: name, fn: fn}
+}
+
+func (d *FuncDetector) Name() string {
+	return d.name
+}
+
+func (d *FuncDetector) Find(text string) []Finding {
+	findings := d.fn(text)
+	for i := range findings {
+		if findings[i].Detector == "" {
+			findings[i].Detector = d.name
+		}
+		if findings[i].Confidence == 0 {
+			findings[i].Confidence = 1
+		}
+	}
+	return findings
+}
+
+// DictionaryDetector reports whole-word occurrences of a fixed list of terms.
+type DictionaryDetector struct {
+	name   string
+	regex  *regexp.Regexp
+	maxLen int
+}
+
+func NewDictionaryDetector(name string, words []string, caseSensitive bool) *DictionaryDetector {
+	terms := make([]string, 0, len(words))
+	maxLen := 0
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			terms = append(terms, regexp.QuoteMeta(word))
+			maxLen = max(maxLen, len(word))
+		}
+	}
+	// Longer terms first so that "New York City" wins over "New York".
+	sort.Slice(terms, func(i, j int) bool {
+		return len(terms[i]) > len(terms[j])
+	})
+	pattern := `\b(?:` + strings.Join(terms, "|") + `)\b`
+	if !caseSensitive {
+		pattern = `(?i)` + pattern
+	}
+	if len(terms) == 0 {
+		pattern = `[^\s\S]`
+	}
+	return &DictionaryDetector{name: name, regex: regexp.MustCompile(pattern), maxLen: maxLen}
+}
+
+func (d *DictionaryDetector) Name() string {
+	return d.name
+}
+
+// MaxLen returns the length in bytes of the longest term.
+func (d *DictionaryDetector) MaxLen() int {
+	return d.maxLen
+}
+
+func (d *DictionaryDetector) Find(text string) []Finding {
+	return regexFindings(text, d.name, d.regex)
+}
+
+// CompositeDetector reports the findings of several detectors under one name.
+// Overlapping findings are merged into the widest one.
+type CompositeDetector struct {
+	name      string
+	detectors []Detector
+}
+
+func NewCompositeDetector(name string, detectors ...Detector) *CompositeDetector {
+	return &CompositeDetector{name: name, detectors: detectors}
+}
+
+func (d *CompositeDetector) Name() string {
+	return d.name
+}
+
+func (d *CompositeDetector) Find(text string) []Finding {
+	var findings []Finding
+	for _, detector := range d.detectors {
+		findings = append(findings, detector.Find(text)...)
+	}
+	sortFindings(findings)
+	var merged []Finding
+	for _, f := range findings {
+		f.Detector = d.name
+		if n := len(merged); n > 0 && f.Start < merged[n-1].End {
+			last := &merged[n-1]
+			if f.End > last.End {
+				last.End = f.End
+				last.Value = text[last.Start:last.End]
+			}
+			if f.Confidence > last.Confidence {
+				last.Confidence = f.Confidence
+			}
+			continue
+		}
+		merged = append(merged, f)
+	}
+	return merged
+}
+
+// Registry is a concurrency-safe set of detectors addressed by name.
+type Registry struct {
+	mu        sync.RWMutex
+	detectors map[string]Detector
+	allow     map[string]*List
+	deny      map[string]*List
+	scanner   *Scanner
+	// localeScanners caches the scanners over named detectors and locale packs.
+	localeScanners map[string]*Scanner
+}
+
+func NewRegistry(detectors ...Detector) *Registry {
+	r := &Registry{
+		detectors: make(map[string]Detector),
+		allow:     make(map[string]*List),
+		deny:      make(map[string]*List),
+	}
+	for _, d := range detectors {
+		_ = r.Register(d)
+	}
+	return r
+}
+
+// Register adds the detector, replacing any detector with the same name.
+func (r *Registry) Register(d Detector) error {
+	if d == nil {
+		return errors.New("detector is nil")
+	}
+	if len(d.Name()) == 0 {
+		return errors.New("detector name is null")
+	}
+	r.mu.Lock()
+	r.detectors[d.Name()] = d
+	r.scanner, r.localeScanners = nil, nil
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Registry) Unregister(name string) error {
+	if len(name) == 0 {
+		return errors.New("detector name is null")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.detectors[name]; !ok {
+		return errors.New("detector is not exists")
+	}
+	delete(r.detectors, name)
+	r.scanner, r.localeScanners = nil, nil
+	return nil
+}
+
+func (r *Registry) Get(name string) (Detector, bool) {
+	r.mu.RLock()
+	d, ok := r.detectors[name]
+	r.mu.RUnlock()
+	return d, ok
+}
+
+// Names returns the sorted names of the registered detectors.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.detectors))
+	for name := range r.detectors {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// Detectors returns the named detectors, or all of them when no name is
+// given. Unknown names are ignored.
+func (r *Registry) Detectors(names ...string) []Detector {
+	if len(names) == 0 {
+		names = r.Names()
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	detectors := make([]Detector, 0, len(names))
+	for _, name := range names {
+		if d, ok := r.detectors[name]; ok {
+			detectors = append(detectors, d)
+		}
+	}
+	return detectors
+}
+
+// Scanner returns a Scanner over the named detectors, or all of them.
+// Scanners are cached until the registry changes.
+func (r *Registry) Scanner(names ...string) *Scanner {
+	if len(names) > 0 {
+		return r.localeScanner(names, nil)
+	}
+	r.mu.RLock()
+	s := r.scanner
+	r.mu.RUnlock()
+	if s != nil {
+		return s
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.scanner == nil {
+		detectors := make([]Detector, 0, len(r.detectors))
+		for _, d := range r.detectors {
+			detectors = append(detectors, d)
+		}
+		r.scanner = NewScanner(detectors...)
+	}
+	return r.scanner
+}
+
+// maxLocaleScanners bounds the number of cached locale scanners.
+const maxLocaleScanners = 64
+
+// localeScanner returns a Scanner over the named detectors, or all of them,
+// and the detectors of the locale packs. Scanners are cached until the
+// registry or the locale packs change.
+func (r *Registry) localeScanner(names, locales []string) *Scanner {
+	key := strings.Join(names, ",") + "|" + strings.ToLower(strings.Join(locales, ",")) + "|" + strconv.FormatUint(localePacksVersion.Load(), 10)
+	r.mu.RLock()
+	s := r.localeScanners[key]
+	r.mu.RUnlock()
+	if s != nil {
+		return s
+	}
+	detectors := r.Detectors(names...)
+	for _, name := range locales {
+		if pack, ok := GetLocalePack(name); ok {
+			detectors = append(detectors, pack.Detectors...)
+		}
+	}
+	s = NewScanner(detectors...)
+	r.mu.Lock()
+	if r.localeScanners == nil || len(r.localeScanners) >= maxLocaleScanners {
+		r.localeScanners = make(map[string]*Scanner)
+	}
+	r.localeScanners[key] = s
+	r.mu.Unlock()
+	return s
+}
+
+// SetAllowList sets the values the named detector, or every detector when
+// name is empty, never reports. A nil list removes it.
+func (r *Registry) SetAllowList(name string, l *List) {
+	r.mu.Lock()
+	r.allow[name] = l
+	r.mu.Unlock()
+}
+
+// SetDenyList sets the values always reported under the named detector, or
+// under DenyListDetector when name is empty. A nil list removes it.
+func (r *Registry) SetDenyList(name string, l *List) {
+	r.mu.Lock()
+	r.deny[name] = l
+	r.mu.Unlock()
+}
+
+// Find runs the named detectors, or all of them, over text and returns the
+// findings reaching MinConfidence ordered by position. Values on the allow
+// lists are left out and values on the deny lists are added.
+func (r *Registry) Find(text string, names ...string) []Finding {
+	return r.find(text, r.Scanner(names...), names)
+}
+
+// find scans text and applies the allow and deny lists of the named detectors.
+func (r *Registry) find(text string, scanner *Scanner, names []string) []Finding {
+	findings := scanner.Scan(text)
+	r.mu.RLock()
+	allowAll, denyAll := r.allow[""], r.deny[""]
+	kept := findings[:0]
+	for _, f := range findings {
+		if f.Confidence < MinConfidence {
+			continue
+		}
+		deny := r.deny[f.Detector]
+		if !denyAll.Contains(f.Value) && !deny.Contains(f.Value) &&
+			(allowAll.Contains(f.Value) || r.allow[f.Detector].Contains(f.Value)) {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	denied := denyAll.Find(text, DenyListDetector)
+	for name, l := range r.deny {
+		if name != "" && (len(names) == 0 || containsString(names, name)) {
+			denied = append(denied, l.Find(text, name)...)
+		}
+	}
+	r.mu.RUnlock()
+	return mergeFindings(kept, denied)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeFindings adds the extra findings to findings, skipping those at the
+// position of another one, and orders them by position.
+func mergeFindings(findings, extra []Finding) []Finding {
+	if len(extra) == 0 {
+		return findings
+	}
+	seen := make(map[[2]int]bool, len(findings)+len(extra))
+	for _, f := range findings {
+		seen[[2]int{f.Start, f.End}] = true
+	}
+	for _, f := range extra {
+		if span := [2]int{f.Start, f.End}; !seen[span] {
+			seen[span] = true
+			findings = append(findings, f)
+		}
+	}
+	sortFindings(findings)
+	return findings
+}
+
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Start != findings[j].Start {
+			return findings[i].Start < findings[j].Start
+		}
+		if findings[i].End != findings[j].End {
+			return findings[i].End > findings[j].End
+		}
+		return findings[i].Detector < findings[j].Detector
+	})
+}
+
+// DefaultRegistry holds the built-in detectors used by FindAll and ParseMultiple.
+var DefaultRegistry = newDefaultRegistry()
+
+// builtinRegexes holds the patterns RegexMap starts with, which the special
+// cases and the anchors of newRegexMapDetector are written for.
+var builtinRegexes = make(map[string]*regexp.Regexp, len(RegexMap))
+
+var (
+	regexMapMu sync.Mutex
+	// regexMapSeen holds the patterns of RegexMap the DefaultRegistry was
+	// last synchronized with.
+	regexMapSeen = make(map[string]*regexp.Regexp, len(RegexMap))
+)
+
+func newDefaultRegistry() *Registry {
+	r := NewRegistry(
+		NewEntropyDetector("high_entropy"),
+		NewNameDetector("person"),
+		NewRegexDetector("address", AddressRegex),
+		NewRegexDetector("coordinates", CoordinatesRegex).WithContext(ContextMap["coordinates"]),
+		NewRegexDetector("organization", OrganizationRegex),
+	)
+	for name, regex := range RegexMap {
+		builtinRegexes[name] = regex
+		regexMapSeen[name] = regex
+		_ = r.Register(newRegexMapDetector(name, regex))
+	}
+	return r
+}
+
+// newRegexMapDetector returns the detector of a pattern of RegexMap.
+func newRegexMapDetector(name string, regex *regexp.Regexp) *RegexDetector {
+	d := NewRegexDetector(name, regex)
+	builtin := builtinRegexes[name] == regex
+	if builtin {
+		// Emails and links are told apart the way ParseEmails and
+		// ParseLinks do it.
+		switch name {
+		case "email":
+			d = NewRegexDetector(name, LinkRegex).WithValidator(isLinkEmail)
+		case "link":
+			d.WithValidator(isLinkURL)
+		case "jwt":
+			d.WithValidator(isJWT)
+		case "bearer_token":
+			d.WithGroup(1)
+		}
+		if anchors, ok := AnchorMap[name]; ok {
+			d.WithAnchors(anchors...)
+		}
+	}
+	if ctx, ok := ContextMap[name]; ok {
+		d.WithContext(ctx)
+	}
+	return d
+}
+
+// defaultRegistry returns the DefaultRegistry once the patterns added to,
+// changed in or removed from RegexMap since the last call are registered or
+// unregistered. Detectors registered under a name of RegexMap are replaced
+// only when its pattern changes.
+func defaultRegistry() *Registry {
+	regexMapMu.Lock()
+	defer regexMapMu.Unlock()
+	for name, regex := range RegexMap {
+		if regexMapSeen[name] != regex {
+			regexMapSeen[name] = regex
+			_ = DefaultRegistry.Register(newRegexMapDetector(name, regex))
+		}
+	}
+	for name := range regexMapSeen {
+		if _, ok := RegexMap[name]; !ok {
+			delete(regexMapSeen, name)
+			_ = DefaultRegistry.Unregister(name)
+		}
+	}
+	return DefaultRegistry
+}
+
+// RegisterDetector adds a detector to the DefaultRegistry.
+func RegisterDetector(d Detector) error {
+	return DefaultRegistry.Register(d)
+}
+
+// UnregisterDetector removes a detector from the DefaultRegistry.
+func UnregisterDetector(name string) error {
+	return DefaultRegistry.Unregister(name)
+}
+
+// SetAllowList sets an allow list of the DefaultRegistry.
+func SetAllowList(name string, l *List) {
+	DefaultRegistry.SetAllowList(name, l)
+}
+
+// SetDenyList sets a deny list of the DefaultRegistry.
+func SetDenyList(name string, l *List) {
+	DefaultRegistry.SetDenyList(name, l)
+}
+
+// GetDetector returns a detector of the DefaultRegistry.
+func GetDetector(name string) (Detector, bool) {
+	return DefaultRegistry.Get(name)
+}
@@ -0,0 +1,201 @@
+package anonymizer
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDetectors(t *testing.T) {
+	text := "order A-17 and B-2 shipped to New York City"
+	tests := []struct {
+		name     string
+		detector Detector
+		want     []string
+	}{
+		{"regex", NewRegexDetector("order", regexp.MustCompile(`[A-Z]-\d+`)), []string{"order[6:10]", "order[15:18]"}},
+		{"regex group", NewRegexDetector("order", regexp.MustCompile(`([A-Z])-\d+`)).WithGroup(1), []string{"order[6:7]", "order[15:16]"}},
+		{"regex validator", NewRegexDetector("order", regexp.MustCompile(`[A-Z]-\d+`)).WithValidator(func(v string) bool { return len(v) > 3 }), []string{"order[6:10]"}},
+		{"func", NewFuncDetector("fixed", func(string) []Finding { return []Finding{{Value: "order", Start: 0, End: 5}} }), []string{"fixed[0:5]"}},
+		{"dictionary longest term", NewDictionaryDetector("city", []string{"new york", " New York City ", ""}, false), []string{"city[30:43]"}},
+		{"dictionary case sensitive", NewDictionaryDetector("city", []string{"new york"}, true), nil},
+		{"dictionary without terms", NewDictionaryDetector("city", nil, false), nil},
+		{
+			"composite merges overlaps",
+			NewCompositeDetector("id",
+				NewRegexDetector("a", regexp.MustCompile(`A-\d+`)),
+				NewRegexDetector("b", regexp.MustCompile(`\d+ and`)),
+				NewRegexDetector("c", regexp.MustCompile(`B-2`))),
+			[]string{"id[6:14]", "id[15:18]"},
+		},
+	}
+	for _, tt := range tests {
+		got := tt.detector.Find(text)
+		if keys := findingKeys(got); !reflect.DeepEqual(keys, tt.want) && len(keys)+len(tt.want) > 0 {
+			t.Errorf("%s: got %v, want %v", tt.name, keys, tt.want)
+		}
+		for _, f := range got {
+			if f.Value != text[f.Start:f.End] || f.Confidence != 1 {
+				t.Errorf("%s: finding %+v", tt.name, f)
+			}
+		}
+	}
+}
+
+func TestRegexDetectorMaxLen(t *testing.T) {
+	if n := NewRegexDetector("zip", regexp.MustCompile(`\d{5}`)).MaxLen(); n != 5 {
+		t.Errorf("MaxLen = %d, want 5", n)
+	}
+	if n := NewRegexDetector("word", regexp.MustCompile(`\w+`)).MaxLen(); n != -1 {
+		t.Errorf("MaxLen = %d, want -1", n)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(NewRegexDetector("zip", regexp.MustCompile(`\b\d{5}\b`)))
+	if err := r.Register(nil); err == nil {
+		t.Error("Register(nil) succeeded")
+	}
+	if err := r.Register(NewFuncDetector("", nil)); err == nil {
+		t.Error("Register without name succeeded")
+	}
+	if err := r.Unregister("missing"); err == nil {
+		t.Error("Unregister of a missing detector succeeded")
+	}
+	if err := r.Unregister(""); err == nil {
+		t.Error("Unregister without name succeeded")
+	}
+
+	text := "ship 44600 to ABC"
+	scanner := r.Scanner()
+	if r.Scanner() != scanner {
+		t.Error("Scanner is not cached")
+	}
+	if got := findingKeys(r.Find(text)); !reflect.DeepEqual(got, []string{"zip[5:10]"}) {
+		t.Errorf("Find = %v", got)
+	}
+	if err := r.Register(NewDictionaryDetector("code", []string{"ABC"}, true)); err != nil {
+		t.Fatal(err)
+	}
+	if r.Scanner() == scanner {
+		t.Error("Scanner is not rebuilt after Register")
+	}
+	if got := r.Names(); !reflect.DeepEqual(got, []string{"code", "zip"}) {
+		t.Errorf("Names = %v", got)
+	}
+	if got := findingKeys(r.Find(text)); !reflect.DeepEqual(got, []string{"zip[5:10]", "code[14:17]"}) {
+		t.Errorf("Find = %v", got)
+	}
+	if got := findingKeys(r.Find(text, "code", "missing")); !reflect.DeepEqual(got, []string{"code[14:17]"}) {
+		t.Errorf("Find(code) = %v", got)
+	}
+	if err := r.Unregister("zip"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Get("zip"); ok {
+		t.Error("zip is still registered")
+	}
+	if got := findingKeys(r.Find(text)); !reflect.DeepEqual(got, []string{"code[14:17]"}) {
+		t.Errorf("Find after Unregister = %v", got)
+	}
+}
+
+func TestRegistryConcurrent(t *testing.T) {
+	r := NewRegistry()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		name := fmt.Sprintf("word%d", i)
+		go func() {
+			defer wg.Done()
+			_ = r.Register(NewDictionaryDetector(name, []string{name}, true))
+		}()
+		go func() {
+			defer wg.Done()
+			r.Find("word1 word2 word3")
+		}()
+	}
+	wg.Wait()
+	if got := len(r.Names()); got != 8 {
+		t.Errorf("%d detectors, want 8", got)
+	}
+	if got := findingKeys(r.Find("word1 word2 word3")); len(got) != 3 {
+		t.Errorf("Find = %v", got)
+	}
+}
+
+func TestRegisterDetector(t *testing.T) {
+	if err := RegisterDetector(NewRegexDetector("ticket", regexp.MustCompile(`TCK-\d+`))); err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterDetector("ticket")
+	if _, ok := GetDetector("ticket"); !ok {
+		t.Fatal("ticket is not registered")
+	}
+	var found []string
+	for _, f := range FindAll("see TCK-42 for details") {
+		found = append(found, f.Detector+":"+f.Value)
+	}
+	if !strings.Contains(strings.Join(found, " "), "ticket:TCK-42") {
+		t.Errorf("FindAll = %v", found)
+	}
+	if got := findingKeys(FindAll("see TCK-42 for details", "ticket")); !reflect.DeepEqual(got, []string{"ticket[4:10]"}) {
+		t.Errorf("FindAll(ticket) = %v", got)
+	}
+}
+
+func TestParseMultipleKeys(t *testing.T) {
+	text := "John Smith of Acme Corp lives at 221 Baker Street, London NW1 6XE (51.5237, -0.1585). " +
+		"Mail john@example.com, see https://example.com/a, call 555-123-4567 on 12/05/2023 at 10:30. Token Xq3vT9kLm2Pz8RwY4nBc7HdJ"
+	got := ParseMultiple(text)
+	keys := make([]string, 0, len(got))
+	for key := range got {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if want := []string{"date", "email", "isbn_13", "link", "phone", "time"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("ParseMultiple keys = %q, want %q", keys, want)
+	}
+	if emails := got["email"]; !reflect.DeepEqual(emails, []string{"john@example.com"}) {
+		t.Errorf("email = %q", emails)
+	}
+	for _, name := range []string{"person", "organization", "address", "coordinates", "high_entropy"} {
+		if values := ParseMultiple(text, name)[name]; len(values) == 0 {
+			t.Errorf("ParseMultiple(%s) found nothing", name)
+		}
+	}
+}
+
+func TestRegexMapChanges(t *testing.T) {
+	defer func(email *regexp.Regexp) {
+		RegexMap["email"] = email
+		delete(RegexMap, "ticket")
+		defaultRegistry()
+	}(RegexMap["email"])
+	text := "see TCK-42 or ana@example.com"
+
+	RegexMap["ticket"] = regexp.MustCompile(`TCK-\d+`)
+	if got := ParseMultiple(text)["ticket"]; !reflect.DeepEqual(got, []string{"TCK-42"}) {
+		t.Errorf("added pattern: ticket = %q", got)
+	}
+	RegexMap["ticket"] = regexp.MustCompile(`TCK-\d`)
+	if got := ParseMultiple(text, "ticket")["ticket"]; !reflect.DeepEqual(got, []string{"TCK-4"}) {
+		t.Errorf("changed pattern: ticket = %q", got)
+	}
+	delete(RegexMap, "ticket")
+	if got := FindAll(text, "ticket"); len(got) != 0 {
+		t.Errorf("removed pattern: FindAll = %v", findingKeys(got))
+	}
+	if _, ok := GetDetector("ticket"); ok {
+		t.Error("removed pattern is still registered")
+	}
+
+	RegexMap["email"] = regexp.MustCompile(`ana@\w+`)
+	if got := ParseMultiple(text)["email"]; !reflect.DeepEqual(got, []string{"ana@example"}) {
+		t.Errorf("replaced pattern: email = %q", got)
+	}
+}
@@ -0,0 +1,99 @@
+package anonymizer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EmailAnonymizer replaces email addresses. Its parameter is a mode:
+//
+//	keep_domain  replaces the local part by a pseudonym, the same for the same address (the default)
+//	fake_domain  also replaces the domain, by its entry in Domains or by a fake one, the same for the same domain
+//	mask[:n]     keeps the first n characters of the local part, 1 by default: s******@gmail.com
+//
+// Values which are not email addresses are searched for addresses in free
+// text, and are otherwise left untouched.
+type EmailAnonymizer struct {
+	Salt    string            `json:"salt"`
+	Domains map[string]string `json:"domains"`
+}
+
+func (a *EmailAnonymizer) Replace(source any, name string) any {
+	switch field := source.(type) {
+	case reflect.Value:
+		field = indirect(field)
+		if field.Kind() != reflect.String {
+			return source
+		}
+		value := field.String()
+		if IsValidEmail(value) {
+			return a.anonymize(value, name)
+		}
+		return a.replaceText(value, name)
+	default:
+		return source
+	}
+}
+
+// IsValidEmail reports whether the whole value is an email address.
+func IsValidEmail(value string) bool {
+	loc := EmailRegex.FindStringIndex(value)
+	return loc != nil && loc[0] == 0 && loc[1] == len(value) && isEmail(value)
+}
+
+func (a *EmailAnonymizer) anonymize(email, param string) string {
+	i := strings.LastIndexByte(email, '@')
+	local, domain := email[:i], email[i+1:]
+	mode, arg, _ := strings.Cut(param, ":")
+	switch mode {
+	case "mask":
+		keep, err := strconv.Atoi(arg)
+		if err != nil || keep < 0 {
+			keep = 1
+		}
+		runes := []rune(local)
+		if keep > len(runes) {
+			keep = len(runes)
+		}
+		return string(runes[:keep]) + strings.Repeat("*", len(runes)-keep) + "@" + domain
+	case "fake_domain":
+		fake, ok := a.Domains[strings.ToLower(domain)]
+		if !ok {
+			fake = strings.ToLower(seededFaker(strings.ToLower(domain), a.Salt).DomainName())
+		}
+		return a.pseudonym(local) + "@" + fake
+	default:
+		return a.pseudonym(local) + "@" + domain
+	}
+}
+
+// pseudonym derives a local part from a hash of the original one.
+func (a *EmailAnonymizer) pseudonym(local string) string {
+	sum := sha256.Sum256([]byte(a.Salt + strings.ToLower(local)))
+	return "user_" + hex.EncodeToString(sum[:6])
+}
+
+// replaceText anonymizes the email addresses found in text the way
+// ParseEmails finds them.
+func (a *EmailAnonymizer) replaceText(text, param string) string {
+	var b strings.Builder
+	last := 0
+	for _, f := range regexFindings(text, "email", LinkRegex) {
+		if !isLinkEmail(f.Value) {
+			continue
+		}
+		// Skip a scheme such as "mailto:".
+		loc := EmailRegex.FindStringIndex(f.Value)
+		if loc == nil || !isEmail(f.Value[loc[0]:loc[1]]) {
+			continue
+		}
+		b.WriteString(text[last : f.Start+loc[0]])
+		b.WriteString(a.anonymize(f.Value[loc[0]:loc[1]], param))
+		last = f.Start + loc[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
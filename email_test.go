@@ -0,0 +1,81 @@
+package anonymizer
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var emailPseudonymRegex = regexp.MustCompile(`^user_[0-9a-f]{12}@`)
+
+func TestIsValidEmail(t *testing.T) {
+	tests := map[string]bool{
+		"ana@example.com":        true,
+		"ana.maria+tag@mail.org": true,
+		"ana@example":            false,
+		"mail ana@example.com":   false,
+		"ana@example.com.":       false,
+		"":                       false,
+	}
+	for value, want := range tests {
+		if got := IsValidEmail(value); got != want {
+			t.Errorf("IsValidEmail(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestEmailAnonymizer(t *testing.T) {
+	a := &EmailAnonymizer{Salt: "s"}
+	tests := []struct {
+		value, param, want string
+	}{
+		{"sujit@gmail.com", "mask", "s****@gmail.com"},
+		{"sujit@gmail.com", "mask:3", "suj**@gmail.com"},
+		{"sujit@gmail.com", "mask:0", "*****@gmail.com"},
+		{"sujit@gmail.com", "mask:9", "sujit@gmail.com"},
+		{"sujit@gmail.com", "mask:-2", "s****@gmail.com"},
+		{"s.baniya@example.de", "mask:2", "s.******@example.de"},
+		{"not an email", "", "not an email"},
+	}
+	for _, tt := range tests {
+		if got := replaceString(a, tt.value, tt.param); got != tt.want {
+			t.Errorf("Replace(%q, %q) = %q, want %q", tt.value, tt.param, got, tt.want)
+		}
+	}
+}
+
+func TestEmailAnonymizerPseudonyms(t *testing.T) {
+	a := &EmailAnonymizer{Salt: "s", Domains: map[string]string{"corp.io": "example.com"}}
+	kept := replaceString(a, "Ana@Gmail.com", "")
+	if !emailPseudonymRegex.MatchString(kept) || !strings.HasSuffix(kept, "@Gmail.com") {
+		t.Errorf("keep_domain = %q", kept)
+	}
+	if again := replaceString(a, "ana@Gmail.com", "keep_domain"); again != kept {
+		t.Errorf("the same address is replaced by %q and %q", kept, again)
+	}
+	if other := replaceString(&EmailAnonymizer{Salt: "t"}, "Ana@Gmail.com", ""); other == kept {
+		t.Error("the salt does not change the pseudonym")
+	}
+
+	mapped := replaceString(a, "ana@Corp.io", "fake_domain")
+	if !emailPseudonymRegex.MatchString(mapped) || !strings.HasSuffix(mapped, "@example.com") {
+		t.Errorf("fake_domain with a mapped domain = %q", mapped)
+	}
+	fake := replaceString(a, "ana@gmail.com", "fake_domain")
+	_, domain, _ := strings.Cut(fake, "@")
+	if !emailPseudonymRegex.MatchString(fake) || domain == "gmail.com" || !IsValidEmail(fake) {
+		t.Errorf("fake_domain = %q", fake)
+	}
+	if bob := replaceString(a, "bob@GMAIL.com", "fake_domain"); !strings.HasSuffix(bob, "@"+domain) {
+		t.Errorf("the domain of %q is replaced by another one than in %q", bob, fake)
+	}
+}
+
+func TestEmailAnonymizerText(t *testing.T) {
+	a := &EmailAnonymizer{}
+	text := "write to sujit@gmail.com or mailto:ana@example.org, not @handle or a@b"
+	want := "write to s****@gmail.com or mailto:a**@example.org, not @handle or a@b"
+	if got := replaceString(a, text, "mask"); got != want {
+		t.Errorf("Replace(%q) = %q, want %q", text, got, want)
+	}
+}
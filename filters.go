@@ -0,0 +1,177 @@
+package anonymizer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// OutputFilter transforms the value of an output placeholder, given the
+// argument of the filter such as the layout in <date|format:2006-01-02>.
+type OutputFilter func(value any, arg string) (any, error)
+
+// OutputFilters holds the filters of output placeholders such as
+// <name|upper> or <card|mask:last4>. Filters run left to right; names of
+// replacers, such as hash or asterisk, are filters as well. Unknown filters
+// are skipped, or an error in strict mode:
+//
+//	upper, lower, title, trim  change the case or trim the value
+//	format:layout              formats a time with a layout, or any value with a fmt verb such as %05d
+//	mask, mask:last4, mask:first2  masks the value with "*", keeping the last or first characters
+//	default:text               is the value of a missing or empty placeholder
+var OutputFilters = map[string]OutputFilter{
+	"upper":   stringFilter(strings.ToUpper),
+	"lower":   stringFilter(strings.ToLower),
+	"title":   stringFilter(titleCase),
+	"trim":    stringFilter(strings.TrimSpace),
+	"format":  formatFilter,
+	"mask":    maskFilter,
+	"default": defaultFilter,
+}
+
+// AddOutputFilter adds a filter of output placeholders.
+func AddOutputFilter(name string, filter OutputFilter) error {
+	if len(name) == 0 {
+		return errors.New("filter name is null")
+	}
+	if filter == nil {
+		return errors.New("filter is nil")
+	}
+	OutputFilters[name] = filter
+	return nil
+}
+
+// outputPlaceholder renders an output placeholder with data. Missing
+// values are empty, or an error in strict mode, unless a default filter
+// gives them a value.
+func outputPlaceholder(placeholder string, data map[string]any, strict bool) (string, error) {
+	parts := strings.Split(placeholder[1:len(placeholder)-1], "|")
+	name := parts[0]
+	value, found := data[name]
+	if !found {
+		value = ""
+		if strict && !hasDefaultFilter(parts[1:]) {
+			return "", fmt.Errorf("placeholder %q is not exists", name)
+		}
+	}
+	for _, filter := range parts[1:] {
+		if !strict && !isOutputFilter(filter) {
+			continue
+		}
+		var err error
+		if value, err = applyOutputFilter(value, filter); err != nil {
+			return "", fmt.Errorf("placeholder %q: %w", name, err)
+		}
+	}
+	return fmt.Sprint(value), nil
+}
+
+func applyOutputFilter(value any, filter string) (any, error) {
+	filterName, arg, _ := strings.Cut(filter, ":")
+	filterName = strings.TrimSpace(filterName)
+	if f, ok := OutputFilters[filterName]; ok {
+		return f(value, arg)
+	}
+	if ruler, ok := lookupReplacer(filterName); ok {
+		return replaceString(ruler, fmt.Sprint(value), arg), nil
+	}
+	return nil, fmt.Errorf("filter %q is not exists", filterName)
+}
+
+// isOutputFilter reports whether a filter is an output filter or a replacer.
+func isOutputFilter(filter string) bool {
+	filterName, _, _ := strings.Cut(filter, ":")
+	filterName = strings.TrimSpace(filterName)
+	if _, ok := OutputFilters[filterName]; ok {
+		return true
+	}
+	_, ok := lookupReplacer(filterName)
+	return ok
+}
+
+func hasDefaultFilter(filters []string) bool {
+	for _, filter := range filters {
+		if name, _, _ := strings.Cut(filter, ":"); strings.TrimSpace(name) == "default" {
+			return true
+		}
+	}
+	return false
+}
+
+func stringFilter(fn func(string) string) OutputFilter {
+	return func(value any, _ string) (any, error) {
+		return fn(fmt.Sprint(value)), nil
+	}
+}
+
+func titleCase(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		defer func() { prev = r }()
+		if unicode.IsSpace(prev) || prev == '-' {
+			return unicode.ToUpper(r)
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+func formatFilter(value any, arg string) (any, error) {
+	if arg == "" {
+		return nil, errors.New("format is null")
+	}
+	if strings.Contains(arg, "%") {
+		if s, ok := value.(string); ok {
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return fmt.Sprintf(arg, n), nil
+			}
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return fmt.Sprintf(arg, f), nil
+			}
+		}
+		return fmt.Sprintf(arg, value), nil
+	}
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(arg), nil
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly, time.TimeOnly} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.Format(arg), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("cannot format %q as a time", fmt.Sprint(value))
+}
+
+func maskFilter(value any, arg string) (any, error) {
+	runes := []rune(fmt.Sprint(value))
+	keep, from := 0, "last"
+	if arg != "" {
+		switch {
+		case strings.HasPrefix(arg, "last"):
+			arg = arg[len("last"):]
+		case strings.HasPrefix(arg, "first"):
+			arg, from = arg[len("first"):], "first"
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid mask %q", arg)
+		}
+		keep = min(n, len(runes))
+	}
+	masked := strings.Repeat("*", len(runes)-keep)
+	if from == "first" {
+		return string(runes[:keep]) + masked, nil
+	}
+	return masked + string(runes[len(runes)-keep:]), nil
+}
+
+func defaultFilter(value any, arg string) (any, error) {
+	if s, ok := value.(string); value == nil || (ok && strings.TrimSpace(s) == "") {
+		return arg, nil
+	}
+	return value, nil
+}
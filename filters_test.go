@@ -0,0 +1,84 @@
+package anonymizer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplaceFilters(t *testing.T) {
+	data := map[string]any{
+		"first": "ada",
+		"last":  "lovelace",
+		"card":  "4111111111111111",
+		"id":    "42",
+		"when":  time.Date(2020, time.January, 12, 10, 30, 0, 0, time.UTC),
+		"day":   "2020-01-12",
+		"blank": " ",
+	}
+	tests := []struct {
+		pattern, want string
+	}{
+		{"<last|upper>, <first|title>", "LOVELACE, Ada"},
+		{"<card|mask:last4>", "************1111"},
+		{"<card|mask:first2>", "41**************"},
+		{"<id|mask>", "**"},
+		{"<id|mask:8>", "42"},
+		{"<id|mask:first8>", "42"},
+		{"<id|format:%05d>", "00042"},
+		{"<when|format:02/01/2006>", "12/01/2020"},
+		{"<day|format:Jan 2>", "Jan 12"},
+		{"<blank|default:none>, <missing|default:n/a>", "none, n/a"},
+		{"<first|trim|upper|mask:last1>", "**A"},
+	}
+	for _, tt := range tests {
+		if got, err := Replace(data, tt.pattern); err != nil || got != tt.want {
+			t.Errorf("Replace(%q) = %q, %v, want %q", tt.pattern, got, err, tt.want)
+		}
+	}
+}
+
+func TestReplaceFilterErrors(t *testing.T) {
+	data := map[string]any{"card": "4111111111111111", "name": "ada"}
+	for _, pattern := range []string{
+		"<card|mask:-2>",
+		"<card|mask:last-2>",
+		"<card|mask:x>",
+		"<name|format:2006>",
+		"<name|format>",
+	} {
+		if got, err := Replace(data, pattern); err == nil {
+			t.Errorf("Replace(%q) = %q, want an error", pattern, got)
+		}
+	}
+	if got, err := Replace(data, "<name|nope|upper>"); err != nil || got != "ADA" {
+		t.Errorf("Replace with an unknown filter = %q, %v, want %q", got, err, "ADA")
+	}
+	if got, err := ReplaceStrict(data, "<name|nope>"); err == nil {
+		t.Errorf("ReplaceStrict with an unknown filter = %q, want an error", got)
+	}
+	if _, err := ReplaceStrict(data, "<missing>"); err == nil {
+		t.Error("ReplaceStrict of a missing placeholder succeeded")
+	}
+	if got, err := ReplaceStrict(data, "<missing|default:-> <name>"); err != nil || got != "- ada" {
+		t.Errorf("ReplaceStrict = %q, %v", got, err)
+	}
+}
+
+func TestAddOutputFilter(t *testing.T) {
+	defer delete(OutputFilters, "reverse")
+	if err := AddOutputFilter("reverse", stringFilter(func(s string) string {
+		r := []rune(s)
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+		return string(r)
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Replace(map[string]any{"name": "abc"}, "<name|reverse|upper>"); err != nil || got != "CBA" {
+		t.Errorf("Replace = %q, %v", got, err)
+	}
+	if err := AddOutputFilter("", nil); err == nil {
+		t.Error("filter without name added")
+	}
+}
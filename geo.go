@@ -0,0 +1,280 @@
+package anonymizer
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const geohashBase32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// metersPerDegree is the length of a degree of latitude.
+const metersPerDegree = 111320.0
+
+// Geohash encodes a coordinate as a geohash of the given number of
+// characters.
+func Geohash(lat, lng float64, precision int) string {
+	latRange, lngRange := [2]float64{-90, 90}, [2]float64{-180, 180}
+	hash := make([]byte, 0, precision)
+	even, bit, ch := true, 0, 0
+	for len(hash) < precision {
+		r, v := &latRange, lat
+		if even {
+			r, v = &lngRange, lng
+		}
+		ch <<= 1
+		if mid := (r[0] + r[1]) / 2; v >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		even = !even
+		if bit++; bit == 5 {
+			hash = append(hash, geohashBase32[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return string(hash)
+}
+
+// Geo blurs geographic coordinates. It handles float fields, [lng, lat]
+// pairs as used by GeoJSON, maps with lat/lng keys or GeoJSON coordinates,
+// and coordinate strings found by CoordinatesRegex. Its parameter is a mode
+// and its argument:
+//
+//	grid:0.01   snaps coordinates to a grid of the given cell size in degrees (the default)
+//	jitter:500  moves coordinates by up to the given number of meters, the same for the same point
+//	geohash:6   replaces coordinates by a geohash of the given precision
+//
+// Lone float fields in geohash mode are snapped to the height of a geohash
+// cell, since the other coordinate is unknown.
+type Geo struct {
+	Salt string `json:"salt"`
+}
+
+type geoMode struct {
+	name string
+	arg  float64
+}
+
+func parseGeoMode(param string) geoMode {
+	name, arg, _ := strings.Cut(param, ":")
+	mode := geoMode{name: name}
+	mode.arg, _ = strconv.ParseFloat(arg, 64)
+	switch name {
+	case "jitter":
+		if mode.arg <= 0 {
+			mode.arg = 500
+		}
+	case "geohash":
+		if mode.arg < 1 {
+			mode.arg = 6
+		}
+	default:
+		mode.name = "grid"
+		if mode.arg <= 0 {
+			mode.arg = 0.01
+		}
+	}
+	return mode
+}
+
+func (g *Geo) Replace(source any, name string) any {
+	field, ok := source.(reflect.Value)
+	if !ok {
+		return source
+	}
+	field = indirect(field)
+	mode := parseGeoMode(name)
+	switch field.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return g.blurValue(field.Float(), mode)
+	case reflect.String:
+		return g.blurText(field.String(), mode)
+	case reflect.Array, reflect.Slice:
+		if lng, lat, ok := coordinatePair(field); ok {
+			return g.blurPair(lat, lng, mode)
+		}
+	case reflect.Map:
+		if out, ok := g.blurMap(field, mode); ok {
+			return out
+		}
+	}
+	return source
+}
+
+// replacesComposite reports whether val is a coordinate pair or object that
+// Replace handles as a whole.
+func (g *Geo) replacesComposite(val reflect.Value) bool {
+	switch val = indirect(val); val.Kind() {
+	case reflect.Array, reflect.Slice:
+		_, _, ok := coordinatePair(val)
+		return ok
+	case reflect.Map:
+		_, ok := g.blurMap(val, geoMode{name: "grid", arg: 1})
+		return ok
+	}
+	return false
+}
+
+func (g *Geo) blurValue(v float64, mode geoMode) float64 {
+	switch mode.name {
+	case "jitter":
+		faker := seededFaker(strconv.FormatFloat(v, 'f', -1, 64), g.Salt)
+		return roundTo(v+faker.Float64Range(-mode.arg, mode.arg)/metersPerDegree, 6)
+	case "geohash":
+		// A geohash of n characters halves latitude 5n/2 times.
+		return snap(v, 180/math.Pow(2, math.Floor(5*mode.arg/2)))
+	default:
+		return snap(v, mode.arg)
+	}
+}
+
+func (g *Geo) blurCoordinates(lat, lng float64, mode geoMode) (float64, float64) {
+	switch mode.name {
+	case "jitter":
+		faker := seededFaker(strconv.FormatFloat(lat, 'f', -1, 64)+","+strconv.FormatFloat(lng, 'f', -1, 64), g.Salt)
+		distance, angle := faker.Float64Range(0, mode.arg), faker.Float64Range(0, 2*math.Pi)
+		lat += distance * math.Cos(angle) / metersPerDegree
+		if c := math.Cos(lat * math.Pi / 180); c > 1e-6 {
+			lng += distance * math.Sin(angle) / (metersPerDegree * c)
+		}
+		return roundTo(math.Max(-90, math.Min(90, lat)), 6), roundTo(math.Mod(lng+540, 360)-180, 6)
+	default:
+		return g.blurValue(lat, mode), g.blurValue(lng, mode)
+	}
+}
+
+// blurPair blurs a GeoJSON [lng, lat] position.
+func (g *Geo) blurPair(lat, lng float64, mode geoMode) any {
+	if mode.name == "geohash" {
+		return Geohash(lat, lng, int(mode.arg))
+	}
+	lat, lng = g.blurCoordinates(lat, lng, mode)
+	return [2]float64{lng, lat}
+}
+
+var (
+	latKeys = []string{"lat", "latitude", "Lat", "Latitude"}
+	lngKeys = []string{"lng", "lon", "long", "longitude", "Lng", "Lon", "Long", "Longitude"}
+)
+
+// blurMap blurs the lat/lng keys or the GeoJSON coordinates of a map. In
+// geohash mode the coordinates are replaced by a "geohash" key.
+func (g *Geo) blurMap(val reflect.Value, mode geoMode) (map[string]any, bool) {
+	if val.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	out := make(map[string]any, val.Len())
+	for _, key := range val.MapKeys() {
+		out[key.String()] = val.MapIndex(key).Interface()
+	}
+	latKey, lat, okLat := floatKey(out, latKeys)
+	lngKey, lng, okLng := floatKey(out, lngKeys)
+	if okLat && okLng {
+		if mode.name == "geohash" {
+			delete(out, latKey)
+			delete(out, lngKey)
+			out["geohash"] = Geohash(lat, lng, int(mode.arg))
+		} else {
+			out[latKey], out[lngKey] = g.blurCoordinates(lat, lng, mode)
+		}
+		return out, true
+	}
+	if coordinates, ok := out["coordinates"]; ok {
+		if lng, lat, ok := coordinatePair(reflect.ValueOf(coordinates)); ok {
+			out["coordinates"] = g.blurPair(lat, lng, mode)
+			return out, true
+		}
+	}
+	return nil, false
+}
+
+func (g *Geo) blurText(text string, mode geoMode) string {
+	matches := CoordinatesRegex.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		lat, _ := strconv.ParseFloat(text[m[2]:m[3]], 64)
+		lng, _ := strconv.ParseFloat(text[m[4]:m[5]], 64)
+		b.WriteString(text[last:m[0]])
+		if mode.name == "geohash" {
+			b.WriteString(Geohash(lat, lng, int(mode.arg)))
+		} else {
+			lat, lng = g.blurCoordinates(lat, lng, mode)
+			b.WriteString(strconv.FormatFloat(lat, 'f', -1, 64))
+			b.WriteString(text[m[3]:m[4]])
+			b.WriteString(strconv.FormatFloat(lng, 'f', -1, 64))
+		}
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// coordinatePair returns the longitude and latitude of a two-number array.
+func coordinatePair(val reflect.Value) (lng, lat float64, ok bool) {
+	for val.Kind() == reflect.Interface && !val.IsNil() {
+		val = val.Elem()
+	}
+	if (val.Kind() != reflect.Array && val.Kind() != reflect.Slice) || val.Len() != 2 {
+		return 0, 0, false
+	}
+	lng, okLng := floatOf(val.Index(0))
+	lat, okLat := floatOf(val.Index(1))
+	return lng, lat, okLng && okLat && math.Abs(lat) <= 90 && math.Abs(lng) <= 180
+}
+
+func floatKey(m map[string]any, keys []string) (string, float64, bool) {
+	for _, key := range keys {
+		if v, ok := m[key]; ok {
+			f, ok := floatOf(reflect.ValueOf(v))
+			return key, f, ok
+		}
+	}
+	return "", 0, false
+}
+
+func floatOf(val reflect.Value) (float64, bool) {
+	for val.Kind() == reflect.Interface && !val.IsNil() {
+		val = val.Elem()
+	}
+	switch val.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return val.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(val.Int()), true
+	case reflect.String:
+		// Numbers decoded with json.Decoder.UseNumber.
+		if n, ok := val.Interface().(json.Number); ok {
+			f, err := n.Float64()
+			return f, err == nil
+		}
+	}
+	return 0, false
+}
+
+// snap rounds v to the nearest multiple of cell, to the decimals of cell.
+func snap(v, cell float64) float64 {
+	decimals := 0
+	if _, frac, ok := strings.Cut(strconv.FormatFloat(cell, 'f', -1, 64), "."); ok {
+		decimals = len(frac)
+	}
+	return roundTo(math.Round(v/cell)*cell, decimals)
+}
+
+func roundTo(v float64, decimals int) float64 {
+	f, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'f', decimals, 64), 64)
+	return f
+}
+
+// ParseCoordinates finds all decimal "lat, lng" coordinates
+func ParseCoordinates(text string) []string {
+	return match(text, CoordinatesRegex)
+}
@@ -0,0 +1,133 @@
+package anonymizer
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestGeohash(t *testing.T) {
+	tests := []struct {
+		lat, lng  float64
+		precision int
+		want      string
+	}{
+		{57.64911, 10.40744, 11, "u4pruydqqvj"},
+		{27.7172, 85.3240, 6, "tuuttt"},
+		{-33.8688, 151.2093, 5, "r3gx2"},
+		{0, 0, 1, "s"},
+	}
+	for _, tt := range tests {
+		if got := Geohash(tt.lat, tt.lng, tt.precision); got != tt.want {
+			t.Errorf("Geohash(%v, %v, %d) = %q, want %q", tt.lat, tt.lng, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestGeoReplace(t *testing.T) {
+	g := &Geo{}
+	tests := []struct {
+		name   string
+		source any
+		param  string
+		want   any
+	}{
+		{"float grid", 27.7172456, "", 27.72},
+		{"float grid size", 27.7172456, "grid:0.1", 27.7},
+		{"float grid quarter", 27.7172456, "grid:0.25", 27.75},
+		{"float32", float32(85.3240123), "grid:1", 85.0},
+		{"float geohash", 27.7172456, "geohash:2", 28.125},
+		{"pair", []float64{85.3240123, 27.7172456}, "", [2]float64{85.32, 27.72}},
+		{"pair of any", []any{85.3240123, 27.7172456}, "grid:0.1", [2]float64{85.3, 27.7}},
+		{"pair geohash", [2]float64{10.40744, 57.64911}, "geohash:5", "u4pru"},
+		{"text", "at 27.7172456, 85.3240123 today", "", "at 27.72, 85.32 today"},
+		{"text geohash", "at 27.7172, 85.3240", "geohash:6", "at tuuttt"},
+		{"text without coordinates", "at home", "", "at home"},
+		{
+			"lat lng map",
+			map[string]any{"lat": 27.7172456, "lng": 85.3240123, "name": "x"},
+			"",
+			map[string]any{"lat": 27.72, "lng": 85.32, "name": "x"},
+		},
+		{
+			"latitude longitude map geohash",
+			map[string]any{"Latitude": 57.64911, "Longitude": 10.40744},
+			"geohash:5",
+			map[string]any{"geohash": "u4pru"},
+		},
+		{
+			"geojson point",
+			map[string]any{"type": "Point", "coordinates": []any{85.3240123, 27.7172456}},
+			"",
+			map[string]any{"type": "Point", "coordinates": [2]float64{85.32, 27.72}},
+		},
+	}
+	for _, tt := range tests {
+		if got := g.Replace(reflect.ValueOf(tt.source), tt.param); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGeoReplaceUnhandled(t *testing.T) {
+	g := &Geo{}
+	for _, source := range []any{
+		[]float64{1, 2, 3},
+		[]float64{200, 10},
+		[]string{"a", "b"},
+		map[string]any{"lat": "north", "lng": 1.0},
+		map[int]float64{1: 2},
+		42,
+	} {
+		val := reflect.ValueOf(source)
+		if got, ok := g.Replace(val, "").(reflect.Value); !ok || got != val {
+			t.Errorf("Replace(%v) = %#v, want the source", source, got)
+		}
+		if source, ok := source.([]float64); ok && len(source) == 2 && g.replacesComposite(val) {
+			t.Errorf("replacesComposite(%v) = true", source)
+		}
+	}
+}
+
+func TestGeoJitter(t *testing.T) {
+	g := &Geo{Salt: "s"}
+	lat, lng := 27.7172456, 85.3240123
+	first := g.Replace(reflect.ValueOf([2]float64{lng, lat}), "jitter:200").([2]float64)
+	second := g.Replace(reflect.ValueOf([2]float64{lng, lat}), "jitter:200").([2]float64)
+	if first != second {
+		t.Errorf("the same point is moved to %v and %v", first, second)
+	}
+	if first == [2]float64{lng, lat} {
+		t.Error("the point is not moved")
+	}
+	dLat := (first[1] - lat) * metersPerDegree
+	dLng := (first[0] - lng) * metersPerDegree * math.Cos(lat*math.Pi/180)
+	if d := math.Hypot(dLat, dLng); d > 201 {
+		t.Errorf("the point is moved by %.0fm, want at most 200m", d)
+	}
+	other := (&Geo{Salt: "t"}).Replace(reflect.ValueOf([2]float64{lng, lat}), "jitter:200").([2]float64)
+	if other == first {
+		t.Error("the salt does not change the jitter")
+	}
+}
+
+func TestAnonymizeGeo(t *testing.T) {
+	type place struct {
+		Name     string     `json:"name"`
+		Location [2]float64 `json:"location" anonymize:"geo:grid:0.1"`
+		Lat      float64    `json:"lat"`
+	}
+	got := Anonymize(place{Name: "x", Location: [2]float64{85.3240123, 27.7172456}, Lat: 27.7172456}, Rule{Field: "lat", Type: "geo"})
+	want := map[string]any{"name": "x", "location": [2]float64{85.3, 27.7}, "lat": 27.72}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Anonymize = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseCoordinates(t *testing.T) {
+	text := "meet at 27.7172, 85.3240 or -33.86882,151.20929; not 1.5, 2.5 or 91.0000, 10.0000"
+	want := []string{"27.7172, 85.3240", "-33.86882,151.20929"}
+	if got := ParseCoordinates(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCoordinates = %q, want %q", got, want)
+	}
+}
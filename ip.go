@@ -0,0 +1,158 @@
+package anonymizer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// IPAnonymizer replaces ip addresses while keeping them valid. Its
+// parameter is a mode and its arguments:
+//
+//	truncate:24:48  zeroes all but the given prefix of IPv4 and IPv6 addresses (the default)
+//	prefix[:key]    pseudonymizes addresses preserving shared prefixes, as Crypto-PAn does
+//	fake            replaces addresses by random ones of the same family, the same for the same address
+//
+// It handles strings, which may hold addresses in free text, and net.IP values.
+type IPAnonymizer struct {
+	Key string `json:"key"`
+}
+
+var ipType = reflect.TypeOf(net.IP{})
+
+func (a *IPAnonymizer) Replace(source any, name string) any {
+	field, ok := source.(reflect.Value)
+	if !ok {
+		return source
+	}
+	field = indirect(field)
+	switch {
+	case field.Type() == ipType:
+		if ip := field.Interface().(net.IP); ip != nil {
+			return a.anonymize(ip, name)
+		}
+	case field.Kind() == reflect.String:
+		if ip := net.ParseIP(field.String()); ip != nil {
+			return a.anonymize(ip, name).String()
+		}
+		return a.replaceText(field.String(), name)
+	}
+	return source
+}
+
+func (a *IPAnonymizer) replacesComposite(val reflect.Value) bool {
+	return val.Type() == ipType
+}
+
+func (a *IPAnonymizer) anonymize(ip net.IP, param string) net.IP {
+	mode, args, _ := strings.Cut(param, ":")
+	switch mode {
+	case "prefix":
+		key := a.Key
+		if args != "" {
+			key = args
+		}
+		return PrefixPreservingIP(ip, key)
+	case "fake":
+		faker := seededFaker(ip.String(), a.Key)
+		if ip.To4() != nil {
+			return net.ParseIP(faker.IPv4Address())
+		}
+		return net.ParseIP(faker.IPv6Address())
+	default:
+		bits4, bits6 := 24, 48
+		v4, v6, _ := strings.Cut(args, ":")
+		if n, err := strconv.Atoi(v4); err == nil {
+			bits4 = n
+		}
+		if n, err := strconv.Atoi(v6); err == nil {
+			bits6 = n
+		}
+		return TruncateIP(ip, bits4, bits6)
+	}
+}
+
+// replaceText anonymizes the addresses found in text by IPv4Regex and
+// IPv6Regex.
+func (a *IPAnonymizer) replaceText(text, param string) string {
+	findings := append(regexFindings(text, "ip6", IPv6Regex), regexFindings(text, "ip4", IPv4Regex)...)
+	sortFindings(findings)
+	var b strings.Builder
+	last := 0
+	for _, f := range findings {
+		// IPv6Regex takes a trailing zone and spaces along.
+		addr, _, _ := strings.Cut(strings.TrimRightFunc(f.Value, isSpace), " ")
+		addr, zone, _ := strings.Cut(addr, "%")
+		ip := net.ParseIP(addr)
+		if f.Start < last || ip == nil || !ipBoundary(text, f.Start, f.Start+len(addr)) {
+			continue
+		}
+		b.WriteString(text[last:f.Start])
+		b.WriteString(a.anonymize(ip, param).String())
+		last = f.Start + len(addr)
+		if zone != "" {
+			b.WriteString("%" + zone)
+			last += 1 + len(zone)
+		}
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// ipBoundary reports whether text[start:end] is not part of a longer run of
+// digits and dots, such as a version number.
+func ipBoundary(text string, start, end int) bool {
+	isPart := func(c byte) bool { return c >= '0' && c <= '9' || c == '.' }
+	return (start == 0 || !isPart(text[start-1])) && (end == len(text) || !isPart(text[end]) || (text[end] == '.' && (end+1 == len(text) || !isPart(text[end+1]))))
+}
+
+// TruncateIP zeroes all but the first bits4 bits of an IPv4 address or the
+// first bits6 bits of an IPv6 address. Prefix lengths out of range are
+// clamped to it.
+func TruncateIP(ip net.IP, bits4, bits6 int) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(max(0, min(bits4, 32)), 32))
+	}
+	return ip.Mask(net.CIDRMask(max(0, min(bits6, 128)), 128))
+}
+
+// PrefixPreservingIP pseudonymizes an ip address with the Crypto-PAn scheme:
+// two addresses sharing a prefix of n bits are mapped to addresses sharing
+// a prefix of n bits, so subnets stay comparable across anonymized logs
+// using the same key.
+func PrefixPreservingIP(ip net.IP, key string) net.IP {
+	addr := []byte(ip.To4())
+	if addr == nil {
+		addr = []byte(ip.To16())
+	}
+	sum := sha256.Sum256([]byte(key))
+	block, _ := aes.NewCipher(sum[:16])
+	var pad [aes.BlockSize]byte
+	block.Encrypt(pad[:], sum[16:])
+	return net.IP(cryptoPAn(block, pad, addr))
+}
+
+func cryptoPAn(block cipher.Block, pad [aes.BlockSize]byte, addr []byte) []byte {
+	out := make([]byte, len(addr))
+	var input, output [aes.BlockSize]byte
+	for i := 0; i < len(addr)*8; i++ {
+		// The input is the first i bits of the address followed by the
+		// remaining bits of the pad.
+		input = pad
+		copy(input[:], addr[:i/8])
+		if rem := i % 8; rem > 0 {
+			mask := byte(0xff) << (8 - rem)
+			input[i/8] = addr[i/8]&mask | pad[i/8]&^mask
+		}
+		block.Encrypt(output[:], input[:])
+		out[i/8] |= (output[0] >> 7) << (7 - i%8)
+	}
+	for i := range out {
+		out[i] ^= addr[i]
+	}
+	return out
+}
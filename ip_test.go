@@ -0,0 +1,121 @@
+package anonymizer
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestTruncateIP(t *testing.T) {
+	tests := []struct {
+		ip           string
+		bits4, bits6 int
+		want         string
+	}{
+		{"192.168.7.9", 24, 48, "192.168.7.0"},
+		{"192.168.7.9", 16, 48, "192.168.0.0"},
+		{"192.168.7.9", 40, 48, "192.168.7.9"},
+		{"192.168.7.9", -1, 48, "0.0.0.0"},
+		{"2001:db8:85a3:8d3:1319:8a2e:370:7348", 24, 48, "2001:db8:85a3::"},
+		{"2001:db8:85a3:8d3:1319:8a2e:370:7348", 24, 64, "2001:db8:85a3:8d3::"},
+		{"::ffff:10.1.2.3", 8, 48, "10.0.0.0"},
+	}
+	for _, tt := range tests {
+		if got := TruncateIP(net.ParseIP(tt.ip), tt.bits4, tt.bits6).String(); got != tt.want {
+			t.Errorf("TruncateIP(%s, %d, %d) = %s, want %s", tt.ip, tt.bits4, tt.bits6, got, tt.want)
+		}
+	}
+}
+
+// sharedBits returns the length of the common prefix of a and b in bits.
+func sharedBits(a, b net.IP) int {
+	for i := range a {
+		if x := a[i] ^ b[i]; x != 0 {
+			n := 0
+			for x&0x80 == 0 {
+				x <<= 1
+				n++
+			}
+			return i*8 + n
+		}
+	}
+	return len(a) * 8
+}
+
+func TestPrefixPreservingIP(t *testing.T) {
+	pairs := [][2]string{
+		{"10.1.2.3", "10.1.2.200"},
+		{"10.1.2.3", "10.1.130.3"},
+		{"10.1.2.3", "192.168.1.1"},
+		{"2001:db8::1", "2001:db8::ff00:1"},
+		{"2001:db8::1", "2001:db9::1"},
+	}
+	for _, pair := range pairs {
+		a, b := net.ParseIP(pair[0]), net.ParseIP(pair[1])
+		if v4 := a.To4(); v4 != nil {
+			a, b = v4, b.To4()
+		}
+		pa, pb := PrefixPreservingIP(a, "key"), PrefixPreservingIP(b, "key")
+		if len(pa) != len(a) {
+			t.Errorf("%s is mapped to %s of another family", a, pa)
+		}
+		if got, want := sharedBits(pa, pb), sharedBits(a, b); got != want {
+			t.Errorf("%s and %s share %d bits, mapped to %s and %s sharing %d", a, b, want, pa, pb, got)
+		}
+		if pa.Equal(a) {
+			t.Errorf("%s is not pseudonymized", a)
+		}
+		if !PrefixPreservingIP(a, "key").Equal(pa) || PrefixPreservingIP(a, "other").Equal(pa) {
+			t.Errorf("%s is not mapped by its key alone", a)
+		}
+	}
+}
+
+func TestIPAnonymizer(t *testing.T) {
+	a := &IPAnonymizer{}
+	tests := []struct {
+		value, param, want string
+	}{
+		{"192.168.7.9", "", "192.168.7.0"},
+		{"192.168.7.9", "truncate:16", "192.168.0.0"},
+		{"2001:db8:85a3:8d3::7348", "truncate:24:32", "2001:db8::"},
+		{"from 10.1.2.3, 10.9.8.7.", "", "from 10.1.2.0, 10.9.8.0."},
+		{"link fe80::1:2%eth0 up", "truncate:24:16", "link fe80::%eth0 up"},
+		{"version 1.2.3.4.5 and 300.1.2.3", "", "version 1.2.3.4.5 and 300.1.2.3"},
+		{"no address", "", "no address"},
+		{"10.1.2.3", "prefix:key", PrefixPreservingIP(net.ParseIP("10.1.2.3").To4(), "key").String()},
+	}
+	for _, tt := range tests {
+		if got := replaceString(a, tt.value, tt.param); got != tt.want {
+			t.Errorf("Replace(%q, %q) = %q, want %q", tt.value, tt.param, got, tt.want)
+		}
+	}
+	if got := a.Replace(reflect.ValueOf(net.ParseIP("10.1.2.3")), ""); !reflect.DeepEqual(got, net.IP{10, 1, 2, 0}) {
+		t.Errorf("Replace(net.IP) = %#v", got)
+	}
+	if !a.replacesComposite(reflect.ValueOf(net.IP{})) || a.replacesComposite(reflect.ValueOf([]byte{})) {
+		t.Error("replacesComposite does not take net.IP alone")
+	}
+}
+
+func TestIPAnonymizerFake(t *testing.T) {
+	a := &IPAnonymizer{Key: "salt"}
+	for _, value := range []string{"10.1.2.3", "2001:db8::1"} {
+		got := replaceString(a, value, "fake")
+		ip := net.ParseIP(got)
+		if ip == nil || got == value || (ip.To4() == nil) != (net.ParseIP(value).To4() == nil) {
+			t.Errorf("Replace(%s, fake) = %s, want an address of the same family", value, got)
+		}
+		if again := replaceString(a, value, "fake"); again != got {
+			t.Errorf("Replace(%s, fake) = %s and %s", value, got, again)
+		}
+	}
+}
+
+func TestAnonymizeIP(t *testing.T) {
+	got := Anonymize(map[string]any{"ip": "10.1.2.3", "note": "seen 10.1.2.3"}, Rule{Field: "ip", Type: "ip"}, Rule{Field: "note", Type: "ip", Value: "truncate:8"})
+	want := map[string]any{"ip": "10.1.2.0", "note": "seen 10.0.0.0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Anonymize = %#v, want %#v", got, want)
+	}
+}
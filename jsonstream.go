@@ -0,0 +1,267 @@
+package anonymizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// AnonymizeJSONStream anonymizes a stream of JSON documents, such as a
+// single document, a top-level array or JSON Lines, and writes each of them
+// on its own line. Documents are rewritten token by token, keeping the order
+// of keys, the text of numbers and the fields without rules.
+//
+// Rules apply to the strings and numbers of the keys named by Rule.Field, or
+// of the dotted paths such as "user.address.city"; array elements take the
+// key of their array. Rules without a field apply to the strings and numbers
+// outside any object, such as scalar documents. Objects and arrays are
+// replaced as a whole by replacers handling them, such as geo.
+func AnonymizeJSONStream(r io.Reader, w io.Writer, rules ...Rule) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	a := &jsonAnonymizer{dec: dec, w: bufio.NewWriter(w), rules: rules}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := a.value(tok, "", ""); err == io.EOF {
+			return io.ErrUnexpectedEOF
+		} else if err != nil {
+			return err
+		}
+		a.w.WriteByte('\n')
+	}
+	return a.w.Flush()
+}
+
+type jsonAnonymizer struct {
+	dec   *json.Decoder
+	w     *bufio.Writer
+	rules []Rule
+}
+
+// value writes the value starting with tok, found under key at path.
+func (a *jsonAnonymizer) value(tok json.Token, key, path string) error {
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			return a.object(path)
+		case '[':
+			return a.array(key, path)
+		}
+		return fmt.Errorf("unexpected %v", t)
+	case string, json.Number:
+		if replaced, ok := a.replace(reflect.ValueOf(t), key, path); ok {
+			tok = replaced
+		}
+	}
+	return a.write(tok)
+}
+
+func (a *jsonAnonymizer) object(path string) error {
+	a.w.WriteByte('{')
+	for first := true; a.dec.More(); first = false {
+		tok, err := a.dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		if !first {
+			a.w.WriteByte(',')
+		}
+		if err := a.write(key); err != nil {
+			return err
+		}
+		a.w.WriteByte(':')
+		if err := a.member(key, joinPath(path, key)); err != nil {
+			return err
+		}
+	}
+	if _, err := a.dec.Token(); err != nil {
+		return err
+	}
+	return a.w.WriteByte('}')
+}
+
+func (a *jsonAnonymizer) array(key, path string) error {
+	a.w.WriteByte('[')
+	for first := true; a.dec.More(); first = false {
+		if !first {
+			a.w.WriteByte(',')
+		}
+		tok, err := a.dec.Token()
+		if err != nil {
+			return err
+		}
+		if err := a.value(tok, key, path); err != nil {
+			return err
+		}
+	}
+	if _, err := a.dec.Token(); err != nil {
+		return err
+	}
+	return a.w.WriteByte(']')
+}
+
+// member writes the value of an object member. When a rule names a
+// composite replacer for it, the value is decoded and replaced as a whole
+// if the replacer takes it, and rewritten token by token otherwise.
+func (a *jsonAnonymizer) member(key, path string) error {
+	if !a.composite(key, path) {
+		tok, err := a.dec.Token()
+		if err != nil {
+			return err
+		}
+		return a.value(tok, key, path)
+	}
+	var raw json.RawMessage
+	if err := a.dec.Decode(&raw); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	if replaced, ok := a.replace(reflect.ValueOf(v), key, path); ok {
+		if m, ok := replaced.(map[string]any); ok {
+			return a.writeObject(m, objectKeys(raw))
+		}
+		return a.write(replaced)
+	}
+	dec = json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	member := &jsonAnonymizer{dec: dec, w: a.w, rules: a.rules}
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	return member.value(tok, key, path)
+}
+
+func (a *jsonAnonymizer) composite(key, path string) bool {
+	for _, rule := range a.rules {
+		if rule.Field != key && rule.Field != path {
+			continue
+		}
+		if ruler, ok := lookupReplacer(rule.Type); ok {
+			if _, ok := ruler.(compositeReplacer); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// replace applies the rules of key or path to val, the last applying rule
+// winning as in AnonymizeMap. Objects and arrays are only replaced by
+// composite replacers taking them. Scalars outside any object take the
+// rules without a field.
+func (a *jsonAnonymizer) replace(val reflect.Value, key, path string) (any, bool) {
+	var value any
+	found := false
+	for _, rule := range a.rules {
+		field := key
+		if rule.Field == path {
+			field = path
+		}
+		if path == "" && !rule.appliesToDocument(valueString(val)) || path != "" && !rule.applies(field, valueString(val)) {
+			continue
+		}
+		if ruler, ok := lookupReplacer(rule.Type); ok {
+			if val.Kind() != reflect.String {
+				if c, isComposite := ruler.(compositeReplacer); !isComposite || !c.replacesComposite(val) {
+					continue
+				}
+			}
+			value, found = ruler.Replace(val, rule.Value), true
+		}
+	}
+	if rv, ok := value.(reflect.Value); ok {
+		value = rv.Interface()
+	}
+	return value, found
+}
+
+// write writes v as JSON, without escaping HTML characters.
+func (a *jsonAnonymizer) write(v any) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := a.w.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return err
+}
+
+// writeObject writes m as a JSON object, with the keys in order first and
+// the others sorted.
+func (a *jsonAnonymizer) writeObject(m map[string]any, order []string) error {
+	keys := make([]string, 0, len(m))
+	for _, key := range order {
+		if _, ok := m[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	var rest []string
+	for key := range m {
+		if !containsString(keys, key) {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	a.w.WriteByte('{')
+	for i, key := range append(keys, rest...) {
+		if i > 0 {
+			a.w.WriteByte(',')
+		}
+		if err := a.write(key); err != nil {
+			return err
+		}
+		a.w.WriteByte(':')
+		if err := a.write(m[key]); err != nil {
+			return err
+		}
+	}
+	return a.w.WriteByte('}')
+}
+
+// objectKeys returns the keys of the JSON object raw in their order.
+func objectKeys(raw []byte) []string {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil
+	}
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return keys
+		}
+		key, _ := tok.(string)
+		keys = append(keys, key)
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return keys
+		}
+	}
+	return keys
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
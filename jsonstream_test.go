@@ -0,0 +1,76 @@
+package anonymizer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func anonymizeJSONStream(t *testing.T, data string, rules ...Rule) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := AnonymizeJSONStream(strings.NewReader(data), &buf, rules...); err != nil {
+		t.Fatalf("AnonymizeJSONStream(%s): %v", data, err)
+	}
+	return buf.String()
+}
+
+func TestAnonymizeJSONStream(t *testing.T) {
+	ana, bob := replaceString(&Hasher{}, "ana@example.com", ""), replaceString(&Hasher{}, "bob@example.com", "")
+	tests := []struct {
+		name  string
+		data  string
+		rules []Rule
+		want  string
+	}{
+		{
+			"json lines keep key order and numbers",
+			"{\"user\":{\"email\":\"ana@example.com\",\"n\":1.50}}\n{\"user\":{\"email\":\"bob@example.com\"},\"a\":[true,null]}\n",
+			[]Rule{{Field: "user.email", Type: "hash"}},
+			`{"user":{"email":"` + ana + `","n":1.50}}` + "\n" + `{"user":{"email":"` + bob + `"},"a":[true,null]}` + "\n",
+		},
+		{
+			"top-level array",
+			`[{"email":"ana@example.com"},{"email":"bob@example.com","id":7}]`,
+			[]Rule{{Field: "email", Type: "hash"}},
+			`[{"email":"` + ana + `"},{"email":"` + bob + `","id":7}]` + "\n",
+		},
+		{
+			"array of ips",
+			`{"z":0,"ips":["10.1.2.3","192.168.7.9"],"a":1}`,
+			[]Rule{{Field: "ips", Type: "ip"}},
+			`{"z":0,"ips":["10.1.2.0","192.168.7.0"],"a":1}` + "\n",
+		},
+		{
+			"nested arrays of ips",
+			`{"hosts":{"ips":[["10.1.2.3"],[]]}}`,
+			[]Rule{{Field: "hosts.ips", Type: "ip"}},
+			`{"hosts":{"ips":[["10.1.2.0"],[]]}}` + "\n",
+		},
+		{
+			"geo object keeps key order",
+			`{"loc":{"lng":85.3240123,"lat":27.7172456,"name":"x"},"p":[85.3240123,27.7172456]}`,
+			[]Rule{{Field: "loc", Type: "geo"}, {Field: "p", Type: "geo"}},
+			`{"loc":{"lng":85.32,"lat":27.72,"name":"x"},"p":[85.32,27.72]}` + "\n",
+		},
+		{
+			"geo object not taken",
+			`{"loc":{"z":"1","a":{"b":2}}}`,
+			[]Rule{{Field: "loc", Type: "geo"}},
+			`{"loc":{"z":"1","a":{"b":2}}}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := anonymizeJSONStream(t, tt.data, tt.rules...); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAnonymizeJSONStreamErrors(t *testing.T) {
+	for _, data := range []string{`{"a":`, `{"a":1]`, `[1,`} {
+		if err := AnonymizeJSONStream(strings.NewReader(data), &bytes.Buffer{}); err == nil {
+			t.Errorf("AnonymizeJSONStream(%s) succeeded", data)
+		}
+	}
+}
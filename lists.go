@@ -0,0 +1,193 @@
+package anonymizer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// List matches values by exact value, regular expression, CIDR range or
+// domain. It is used as an allowlist of values that are never flagged or
+// anonymized and as a denylist of values that always are.
+type List struct {
+	Values  []string `json:"values"`
+	Regexes []string `json:"regexes"`
+	CIDRs   []string `json:"cidrs"`
+	Domains []string `json:"domains"`
+
+	once    sync.Once
+	err     error
+	values  map[string]struct{}
+	regexes []*regexp.Regexp
+	nets    []*net.IPNet
+	domains []string
+}
+
+// Compile parses the regexes and CIDR ranges of the list. It is called
+// lazily by Contains and Find, which skip the invalid entries; call it up
+// front to get their errors.
+func (l *List) Compile() error {
+	l.once.Do(func() {
+		var errs []error
+		l.values = make(map[string]struct{}, len(l.Values))
+		for _, v := range l.Values {
+			l.values[v] = struct{}{}
+		}
+		for _, pattern := range l.Regexes {
+			rex, err := regexp.Compile(pattern)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("list regex %q: %w", pattern, err))
+				continue
+			}
+			l.regexes = append(l.regexes, rex)
+		}
+		for _, cidr := range l.CIDRs {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("list cidr %q: %w", cidr, err))
+				continue
+			}
+			l.nets = append(l.nets, ipNet)
+		}
+		for _, domain := range l.Domains {
+			l.domains = append(l.domains, strings.ToLower(strings.TrimPrefix(domain, ".")))
+		}
+		l.err = errors.Join(errs...)
+	})
+	return l.err
+}
+
+// Contains reports whether the whole value is on the list. A value is on
+// a domain entry when it is that domain, one of its subdomains, or an email
+// address or url on it.
+func (l *List) Contains(value string) bool {
+	if l == nil {
+		return false
+	}
+	_ = l.Compile()
+	if _, ok := l.values[value]; ok {
+		return true
+	}
+	for _, rex := range l.regexes {
+		if loc := rex.FindStringIndex(value); loc != nil && loc[0] == 0 && loc[1] == len(value) {
+			return true
+		}
+	}
+	if len(l.nets) > 0 {
+		if ip := net.ParseIP(strings.TrimSpace(value)); ip != nil {
+			for _, ipNet := range l.nets {
+				if ipNet.Contains(ip) {
+					return true
+				}
+			}
+		}
+	}
+	if len(l.domains) > 0 {
+		host := hostOf(value)
+		for _, domain := range l.domains {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Find returns the occurrences of list entries in text, reported under the
+// given detector name.
+func (l *List) Find(text, name string) []Finding {
+	if l == nil {
+		return nil
+	}
+	_ = l.Compile()
+	var findings []Finding
+	for value := range l.values {
+		if value == "" {
+			continue
+		}
+		for offset := 0; ; {
+			i := strings.Index(text[offset:], value)
+			if i < 0 {
+				break
+			}
+			start := offset + i
+			findings = append(findings, Finding{Detector: name, Value: value, Start: start, End: start + len(value), Confidence: 1})
+			offset = start + len(value)
+		}
+	}
+	for _, rex := range l.regexes {
+		findings = append(findings, regexFindings(text, name, rex)...)
+	}
+	if len(l.nets) > 0 {
+		for _, f := range regexFindings(text, name, IPRegex) {
+			f.Value = strings.TrimRightFunc(f.Value, isSpace)
+			f.End = f.Start + len(f.Value)
+			if l.Contains(f.Value) {
+				findings = append(findings, f)
+			}
+		}
+	}
+	if len(l.domains) > 0 {
+		for _, f := range regexFindings(text, name, LinkRegex) {
+			if l.Contains(f.Value) {
+				findings = append(findings, f)
+			}
+		}
+	}
+	// Addresses can be both on a CIDR and a domain entry.
+	return mergeFindings(nil, findings)
+}
+
+// hostOf returns the lower-cased host of an email address, url or bare
+// domain name.
+func hostOf(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if i := strings.LastIndexByte(value, '@'); i >= 0 && !strings.Contains(value, "://") {
+		return value[i+1:]
+	}
+	if !strings.Contains(value, "://") {
+		value = "//" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return value
+	}
+	return u.Hostname()
+}
+
+func isSpace(r rune) bool {
+	return strings.ContainsRune(asciiSpace, r)
+}
+
+// applies reports whether the rule is to anonymize value of the named field
+// or detector. Denied values are always anonymized, even by a rule without a
+// field; allowed values never are.
+func (rule Rule) applies(field, value string) bool {
+	if rule.Field != "" && rule.Field != field {
+		return false
+	}
+	if rule.Deny.Contains(value) {
+		return true
+	}
+	if rule.Field == "" {
+		return false
+	}
+	return !rule.Allow.Contains(value)
+}
+
+// appliesToDocument reports whether the rule applies to value, a scalar
+// outside any object of a document: rules without a field do, unless their
+// allow list holds it.
+func (rule Rule) appliesToDocument(value string) bool {
+	return rule.Field == "" && (rule.Deny.Contains(value) || !rule.Allow.Contains(value))
+}
+
+// allows reports whether the rule keeps value of the named field or
+// detector untouched.
+func (rule Rule) allows(field, value string) bool {
+	return rule.Field == field && rule.Allow.Contains(value) && !rule.Deny.Contains(value)
+}
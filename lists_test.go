@@ -0,0 +1,128 @@
+package anonymizer
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestListContains(t *testing.T) {
+	l := &List{
+		Values:  []string{"admin"},
+		Regexes: []string{`test-\d+`},
+		CIDRs:   []string{"10.0.0.0/8", "2001:db8::/32"},
+		Domains: []string{".Example.com"},
+	}
+	if err := l.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]bool{
+		"admin":                    true,
+		"admins":                   false,
+		"test-42":                  true,
+		"a test-42":                false,
+		"10.1.2.3":                 true,
+		" 10.1.2.3 ":               true,
+		"11.1.2.3":                 false,
+		"2001:db8::1":              true,
+		"example.com":              true,
+		"mail.example.com":         true,
+		"ana@Mail.Example.com":     true,
+		"https://example.com/path": true,
+		"notexample.com":           false,
+		"ana@example.org":          false,
+	}
+	for value, want := range tests {
+		if got := l.Contains(value); got != want {
+			t.Errorf("Contains(%q) = %v, want %v", value, got, want)
+		}
+	}
+	var nilList *List
+	if nilList.Contains("admin") || nilList.Find("admin", "x") != nil {
+		t.Error("nil list holds values")
+	}
+}
+
+func TestListCompileErrors(t *testing.T) {
+	l := &List{Values: []string{"ok"}, Regexes: []string{"("}, CIDRs: []string{"10.0.0.0/33"}}
+	if err := l.Compile(); err == nil {
+		t.Fatal("Compile succeeded")
+	}
+	if !l.Contains("ok") {
+		t.Error("the valid entries of a list with errors are skipped")
+	}
+}
+
+func TestListFind(t *testing.T) {
+	l := &List{Values: []string{"Project X"}, Regexes: []string{`EMP-\d+`}, CIDRs: []string{"10.0.0.0/8"}, Domains: []string{"corp.io"}}
+	text := "Project X by EMP-7 from 10.2.3.4 and 192.168.1.1, mail ana@corp.io or bob@other.io, Project X"
+	want := []string{"x[0:9]", "x[13:18]", "x[24:32]", "x[55:66]", "x[84:93]"}
+	if got := findingKeys(l.Find(text, "x")); !reflect.DeepEqual(got, want) {
+		t.Errorf("Find = %v, want %v", got, want)
+	}
+}
+
+func TestRegistryLists(t *testing.T) {
+	r := NewRegistry(
+		NewRegexDetector("ip", regexp.MustCompile(`\b\d+\.\d+\.\d+\.\d+\b`)),
+		NewRegexDetector("id", regexp.MustCompile(`\bID-\d+\b`)),
+	)
+	text := "from 10.0.0.1 and 8.8.8.8 as ID-1 and ID-2, owner root"
+	r.SetAllowList("ip", &List{CIDRs: []string{"10.0.0.0/8"}})
+	r.SetAllowList("", &List{Values: []string{"ID-1", "ID-2"}})
+	r.SetDenyList("id", &List{Values: []string{"ID-2"}})
+	r.SetDenyList("", &List{Values: []string{"root"}})
+	want := []string{"ip[18:25]", "id[38:42]", "denylist[50:54]"}
+	if got := findingKeys(r.Find(text)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Find = %v, want %v", got, want)
+	}
+	// The deny list of a detector applies when the detector runs.
+	if got := findingKeys(r.Find(text, "ip")); !reflect.DeepEqual(got, []string{"ip[18:25]", "denylist[50:54]"}) {
+		t.Errorf("Find(ip) = %v", got)
+	}
+	r.SetAllowList("ip", nil)
+	r.SetDenyList("", nil)
+	if got := findingKeys(r.Find(text, "ip")); !reflect.DeepEqual(got, []string{"ip[5:13]", "ip[18:25]"}) {
+		t.Errorf("Find(ip) without lists = %v", got)
+	}
+}
+
+func TestPolicyLists(t *testing.T) {
+	r := NewRegistry(NewRegexDetector("email", regexp.MustCompile(`\b\S+@\S+\.\w+\b`)))
+	text := "ana@corp.io, bob@gmail.com and carl@corp.io wrote to Project Falcon"
+	policy := Policy{
+		Registry: r,
+		Allow:    &List{Domains: []string{"corp.io"}},
+		Deny:     &List{Values: []string{"carl@corp.io", "Project Falcon"}},
+	}
+	want := "ana@corp.io, [EMAIL] and [EMAIL] wrote to [DENYLIST]"
+	if got := Redact(text, policy); got != want {
+		t.Errorf("Redact = %q, want %q", got, want)
+	}
+
+	policy = Policy{
+		Registry: r,
+		Rules: []Rule{
+			{Field: "email", Type: "asterisk", Allow: &List{Values: []string{"bob@gmail.com"}}},
+			{Field: "project", Type: "empty", Deny: &List{Regexes: []string{`Project \w+`}}},
+		},
+	}
+	want = "***********, bob@gmail.com and ************ wrote to "
+	if got := Redact(text, policy); got != want {
+		t.Errorf("Redact with rule lists = %q, want %q", got, want)
+	}
+}
+
+func TestRuleLists(t *testing.T) {
+	data := `{"email":"ana@corp.io","backup":"bob@gmail.com","owner":"root"}`
+	rules := []Rule{
+		{Field: "email", Type: "empty", Allow: &List{Domains: []string{"corp.io"}}},
+		{Field: "backup", Type: "empty", Allow: &List{Domains: []string{"corp.io"}}},
+		{Type: "asterisk", Deny: &List{Values: []string{"root"}}},
+	}
+	want := `{"email":"ana@corp.io","backup":"","owner":"****"}`
+	got, err := AnonymizeJSON([]byte(data), rules...)
+	if err != nil || string(got) != want {
+		t.Errorf("AnonymizeJSON = %s, %v, want %s", got, err, want)
+	}
+}
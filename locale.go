@@ -0,0 +1,356 @@
+package anonymizer
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// LocalePack groups the detectors of the identifiers of a country or region,
+// such as national ID numbers and phone numbers. Packs are enabled per
+// Policy through Policy.Locales or per Registry through EnableLocales.
+type LocalePack struct {
+	Name      string
+	Detectors []Detector
+}
+
+var (
+	AadhaarPattern       = `\b[2-9]\d{3}[ -]?\d{4}[ -]?\d{4}\b`
+	PANPattern           = `\b[A-Z]{3}[ABCFGHLJPT][A-Z]\d{4}[A-Z]\b`
+	NINOPattern          = `\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`
+	NHSNumberPattern     = `\b\d{3}[ -]?\d{3}[ -]?\d{4}\b`
+	SteuerIDPattern      = `\b[1-9]\d[ ]?\d{3}[ ]?\d{3}[ ]?\d{3}\b`
+	NIRPattern           = `\b[12][ ]?\d{2}[ ]?\d{2}[ ]?(?:\d{2}|2[ABab])[ ]?\d{3}[ ]?\d{3}[ ]?\d{2}\b`
+	DNIPattern           = `\b\d{8}-?[A-Za-z]\b`
+	NIEPattern           = `\b[XYZxyz]-?\d{7}-?[A-Za-z]\b`
+	NPCitizenshipPattern = `\b\d{2}-\d{2}-\d{2}-\d{5}\b|\b\d{1,3}-\d{1,3}-\d{1,4}/\d{1,6}\b`
+)
+
+var (
+	AadhaarRegex       = regexp.MustCompile(AadhaarPattern)
+	PANRegex           = regexp.MustCompile(PANPattern)
+	NINORegex          = regexp.MustCompile(NINOPattern)
+	NHSNumberRegex     = regexp.MustCompile(NHSNumberPattern)
+	SteuerIDRegex      = regexp.MustCompile(SteuerIDPattern)
+	NIRRegex           = regexp.MustCompile(NIRPattern)
+	DNIRegex           = regexp.MustCompile(DNIPattern)
+	NIERegex           = regexp.MustCompile(NIEPattern)
+	NPCitizenshipRegex = regexp.MustCompile(NPCitizenshipPattern)
+)
+
+// Nepali citizenship numbers have no check digit, so they are only reported
+// near one of these words.
+var citizenshipContext = Context{Words: []string{"citizenship", "nagarikta", "ctzn", "ctz", "नागरिकता"}, Score: 0.4}
+
+var (
+	localePacksMu sync.RWMutex
+	// localePacksVersion changes with the locale packs, to tell the cached
+	// scanners using them are stale.
+	localePacksVersion atomic.Uint64
+	localePacks        = map[string]*LocalePack{}
+)
+
+func init() {
+	for _, pack := range []*LocalePack{
+		{Name: "in", Detectors: []Detector{
+			NewRegexDetector("in_aadhaar", AadhaarRegex).WithValidator(IsValidAadhaar),
+			NewRegexDetector("in_pan", PANRegex),
+			NewPhoneDetector("phone_in", "IN"),
+		}},
+		{Name: "gb", Detectors: []Detector{
+			NewRegexDetector("gb_nino", NINORegex).WithValidator(IsValidNINO),
+			NewRegexDetector("gb_nhs", NHSNumberRegex).WithValidator(IsValidNHSNumber),
+			NewPhoneDetector("phone_gb", "GB"),
+		}},
+		{Name: "de", Detectors: []Detector{
+			NewRegexDetector("de_steuer_id", SteuerIDRegex).WithValidator(IsValidSteuerID),
+			NewPhoneDetector("phone_de", "DE"),
+		}},
+		{Name: "fr", Detectors: []Detector{
+			NewRegexDetector("fr_nir", NIRRegex).WithValidator(IsValidNIR),
+			NewPhoneDetector("phone_fr", "FR"),
+		}},
+		{Name: "es", Detectors: []Detector{
+			NewRegexDetector("es_dni", DNIRegex).WithValidator(IsValidDNI),
+			NewRegexDetector("es_nie", NIERegex).WithValidator(IsValidNIE),
+			NewPhoneDetector("phone_es", "ES"),
+		}},
+		{Name: "np", Detectors: []Detector{
+			NewRegexDetector("np_citizenship", NPCitizenshipRegex).WithContext(citizenshipContext),
+			NewPhoneDetector("phone_np", "NP"),
+		}},
+		{Name: "us", Detectors: []Detector{
+			NewRegexDetector("us_ssn", SSNRegex).WithValidator(IsValidSSN),
+			NewPhoneDetector("phone_us", "US"),
+		}},
+	} {
+		_ = RegisterLocalePack(pack)
+	}
+}
+
+// RegisterLocalePack adds a locale pack, replacing any pack with the same name.
+func RegisterLocalePack(pack *LocalePack) error {
+	if pack == nil {
+		return errors.New("locale pack is nil")
+	}
+	if len(pack.Name) == 0 {
+		return errors.New("locale pack name is null")
+	}
+	localePacksMu.Lock()
+	localePacks[strings.ToLower(pack.Name)] = pack
+	localePacksVersion.Add(1)
+	localePacksMu.Unlock()
+	return nil
+}
+
+func GetLocalePack(name string) (*LocalePack, bool) {
+	localePacksMu.RLock()
+	pack, ok := localePacks[strings.ToLower(name)]
+	localePacksMu.RUnlock()
+	return pack, ok
+}
+
+// LocalePackNames returns the sorted names of the registered locale packs.
+func LocalePackNames() []string {
+	localePacksMu.RLock()
+	names := make([]string, 0, len(localePacks))
+	for name := range localePacks {
+		names = append(names, name)
+	}
+	localePacksMu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// localeDetectors returns the detectors of the named packs.
+func localeDetectors(names ...string) ([]Detector, error) {
+	var detectors []Detector
+	for _, name := range names {
+		pack, ok := GetLocalePack(name)
+		if !ok {
+			return nil, fmt.Errorf("locale pack %q is not exists", name)
+		}
+		detectors = append(detectors, pack.Detectors...)
+	}
+	return detectors, nil
+}
+
+// EnableLocales registers the detectors of the named locale packs.
+func (r *Registry) EnableLocales(names ...string) error {
+	detectors, err := localeDetectors(names...)
+	if err != nil {
+		return err
+	}
+	for _, d := range detectors {
+		if err := r.Register(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnableLocales registers the detectors of the named locale packs in the
+// DefaultRegistry.
+func EnableLocales(names ...string) error {
+	return DefaultRegistry.EnableLocales(names...)
+}
+
+// digitsOf returns the ASCII digits of s.
+func digitsOf(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if '0' <= s[i] && s[i] <= '9' {
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+var (
+	verhoeffMul = [10][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+		{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+		{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+		{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+		{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+		{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+		{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+		{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+		{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+	}
+	verhoeffPerm = [8][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+		{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+		{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+		{9, 4, 5, 3, 1, 2, 6, 8, 7, 0},
+		{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+		{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+		{7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+	}
+)
+
+// IsValidVerhoeff reports whether the digits of s end with a correct
+// Verhoeff check digit.
+func IsValidVerhoeff(s string) bool {
+	digits := digitsOf(s)
+	if digits == "" {
+		return false
+	}
+	c := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		c = verhoeffMul[c][verhoeffPerm[i%8][d]]
+	}
+	return c == 0
+}
+
+// IsValidAadhaar reports whether s is a 12 digit Aadhaar number with a
+// valid Verhoeff check digit.
+func IsValidAadhaar(s string) bool {
+	digits := digitsOf(s)
+	return len(digits) == 12 && digits[0] >= '2' && IsValidVerhoeff(digits)
+}
+
+// IsValidNINO reports whether s is a UK National Insurance number with an
+// allocated prefix.
+func IsValidNINO(s string) bool {
+	s = strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+	if len(s) != 9 {
+		return false
+	}
+	switch s[:2] {
+	case "BG", "GB", "NK", "KN", "TN", "NT", "ZZ":
+		return false
+	}
+	return true
+}
+
+// IsValidNHSNumber reports whether s is a 10 digit NHS number with a valid
+// modulus 11 check digit.
+func IsValidNHSNumber(s string) bool {
+	digits := digitsOf(s)
+	if len(digits) != 10 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(digits[i]-'0') * (10 - i)
+	}
+	check := 11 - sum%11
+	if check == 11 {
+		check = 0
+	}
+	return check != 10 && check == int(digits[9]-'0')
+}
+
+// IsValidSteuerID reports whether s is an 11 digit German tax identification
+// number: one digit of the first ten occurs twice or three times, and the
+// last digit is the ISO 7064 MOD 11,10 check digit.
+func IsValidSteuerID(s string) bool {
+	digits := digitsOf(s)
+	if len(digits) != 11 || digits[0] == '0' {
+		return false
+	}
+	var counts [10]int
+	for i := 0; i < 10; i++ {
+		counts[digits[i]-'0']++
+	}
+	repeated := 0
+	for _, c := range counts {
+		switch {
+		case c == 2 || c == 3:
+			repeated++
+		case c > 3:
+			return false
+		}
+	}
+	if repeated != 1 {
+		return false
+	}
+	product := 10
+	for i := 0; i < 10; i++ {
+		sum := (int(digits[i]-'0') + product) % 10
+		if sum == 0 {
+			sum = 10
+		}
+		product = (2 * sum) % 11
+	}
+	check := 11 - product
+	if check == 10 {
+		check = 0
+	}
+	return check == int(digits[10]-'0')
+}
+
+// IsValidNIR reports whether s is a French social security number whose
+// last two digits are 97 minus the first thirteen modulo 97. The Corsican
+// departments 2A and 2B count as 19 and 18.
+func IsValidNIR(s string) bool {
+	s = strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+	if len(s) != 15 {
+		return false
+	}
+	body := s[:13]
+	switch s[5:7] {
+	case "2A":
+		body = body[:5] + "19" + body[7:]
+	case "2B":
+		body = body[:5] + "18" + body[7:]
+	}
+	if digitsOf(body) != body || digitsOf(s[13:]) != s[13:] {
+		return false
+	}
+	mod := 0
+	for i := 0; i < len(body); i++ {
+		mod = (mod*10 + int(body[i]-'0')) % 97
+	}
+	key := int(s[13]-'0')*10 + int(s[14]-'0')
+	return key == 97-mod
+}
+
+const dniLetters = "TRWAGMYFPDXBNJZSQVHLCKE"
+
+// IsValidDNI reports whether s is a Spanish DNI whose letter matches its
+// number.
+func IsValidDNI(s string) bool {
+	s = strings.ToUpper(strings.ReplaceAll(s, "-", ""))
+	if len(s) != 9 || digitsOf(s[:8]) != s[:8] {
+		return false
+	}
+	n := 0
+	for i := 0; i < 8; i++ {
+		n = n*10 + int(s[i]-'0')
+	}
+	return dniLetters[n%23] == s[8]
+}
+
+// IsValidNIE reports whether s is a Spanish foreigner identity number whose
+// letter matches its number, X, Y and Z counting as 0, 1 and 2.
+func IsValidNIE(s string) bool {
+	s = strings.ToUpper(strings.ReplaceAll(s, "-", ""))
+	if len(s) != 9 {
+		return false
+	}
+	prefix := strings.IndexByte("XYZ", s[0])
+	if prefix < 0 {
+		return false
+	}
+	return IsValidDNI(string(rune('0'+prefix)) + s[1:])
+}
+
+// IsValidSSN reports whether s is a US social security number with an
+// assignable area, group and serial.
+func IsValidSSN(s string) bool {
+	digits := digitsOf(s)
+	if len(digits) != 9 {
+		return false
+	}
+	area, group, serial := digits[:3], digits[3:5], digits[5:]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
@@ -0,0 +1,85 @@
+package anonymizer
+
+import (
+	"testing"
+)
+
+func TestPolicyLocales(t *testing.T) {
+	r := NewRegistry(NewRegexDetector("email", EmailRegex))
+	policy := Policy{Registry: r, Locales: []string{"es", "gb"}}
+	text := "DNI 12345678Z, NHS 943 476 5919, mail ana@example.com, DNI 12345678A"
+	var got []string
+	for _, f := range policy.Find(text) {
+		got = append(got, f.Detector+":"+f.Value)
+	}
+	want := []string{"es_dni:12345678Z", "gb_nhs:943 476 5919", "email:ana@example.com"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestPolicyLocalesScannerCached(t *testing.T) {
+	r := NewRegistry(NewRegexDetector("email", EmailRegex))
+	policy := Policy{Registry: r, Locales: []string{"es"}}
+	policy.Find("12345678Z")
+	s := r.localeScanner(policy.Detectors, policy.Locales)
+	policy.Find("12345678Z")
+	if r.localeScanner(policy.Detectors, policy.Locales) != s {
+		t.Fatal("scanner of the same locales is built again")
+	}
+	if r.localeScanner(nil, []string{"gb"}) == s {
+		t.Fatal("scanner of other locales is reused")
+	}
+
+	if err := r.Register(NewRegexDetector("ipv4", IPv4Regex)); err != nil {
+		t.Fatal(err)
+	}
+	if r.localeScanner(policy.Detectors, policy.Locales) == s {
+		t.Fatal("scanner is reused after Register")
+	}
+	if findings := policy.Find("10.1.2.3"); len(findings) != 1 || findings[0].Detector != "ipv4" {
+		t.Fatalf("findings after Register = %+v", findings)
+	}
+
+	pack, _ := GetLocalePack("es")
+	defer RegisterLocalePack(pack)
+	if err := RegisterLocalePack(&LocalePack{Name: "ES"}); err != nil {
+		t.Fatal(err)
+	}
+	if findings := policy.Find("12345678Z"); len(findings) != 0 {
+		t.Fatalf("findings after RegisterLocalePack = %+v", findings)
+	}
+}
+
+func TestLocaleValidators(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid func(string) bool
+		ok    []string
+		bad   []string
+	}{
+		{"aadhaar", IsValidAadhaar, []string{"2345 6789 0124"}, []string{"2345 6789 0123", "1234 5678 9012"}},
+		{"nino", IsValidNINO, []string{"AB123456C", "AB 12 34 56 C"}, []string{"BG123456C", "ZZ123456C"}},
+		{"nhs", IsValidNHSNumber, []string{"943 476 5919"}, []string{"943 476 5918"}},
+		{"dni", IsValidDNI, []string{"12345678Z", "12345678-z"}, []string{"12345678A"}},
+		{"nie", IsValidNIE, []string{"X1234567L"}, []string{"X1234567A"}},
+		{"ssn", IsValidSSN, []string{"123-45-6789"}, []string{"000-45-6789", "666-45-6789", "123-00-6789"}},
+	}
+	for _, tt := range tests {
+		for _, s := range tt.ok {
+			if !tt.valid(s) {
+				t.Errorf("%s: %q is not valid", tt.name, s)
+			}
+		}
+		for _, s := range tt.bad {
+			if tt.valid(s) {
+				t.Errorf("%s: %q is valid", tt.name, s)
+			}
+		}
+	}
+}
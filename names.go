@@ -0,0 +1,277 @@
+package anonymizer
+
+import (
+	"bufio"
+	"embed"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed dictionaries/*.txt
+var dictionaryFS embed.FS
+
+// NameLocales lists the locales of the embedded name dictionaries.
+var NameLocales = []string{"en", "ne", "in", "es", "de", "fr"}
+
+var honorifics = toSet([]string{
+	"mr", "mrs", "ms", "miss", "mx", "dr", "prof", "sir", "madam", "dame", "lord", "lady",
+	"shri", "sri", "smt", "kumari", "herr", "frau", "sr", "sra", "srta", "mme", "mlle",
+})
+
+// nameStopWords are capitalized words that never continue a name.
+var nameStopWords = toSet([]string{
+	"the", "a", "an", "and", "or", "in", "on", "at", "of", "to", "for", "from", "with", "by",
+	"i", "is", "was", "has", "his", "her", "he", "she", "it", "we", "they", "you",
+	"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday",
+	"january", "february", "march", "april", "june", "july", "august",
+	"september", "october", "november", "december",
+})
+
+var nameTokenRegex = regexp.MustCompile(`\p{L}[\p{L}\p{M}'’-]*\.?`)
+
+// NameDetector finds person names in free text. A capitalized word from the
+// first name dictionary followed by capitalized words, or any capitalized
+// words following an honorific such as "Mr." or "Dr.", make a name. An
+// honorific ending a street, as in "12 Oak Dr.", is not one. The
+// confidence grows when a following word is a known surname; a lone first
+// name needs the words of Context nearby.
+type NameDetector struct {
+	name       string
+	mu         sync.RWMutex
+	firstNames map[string]struct{}
+	surnames   map[string]struct{}
+	Context    *Context
+}
+
+// NewNameDetector returns a detector using the embedded dictionaries of the
+// given locales, or of all NameLocales when none is given.
+func NewNameDetector(name string, locales ...string) *NameDetector {
+	if len(locales) == 0 {
+		locales = NameLocales
+	}
+	d := &NameDetector{
+		name:       name,
+		firstNames: make(map[string]struct{}),
+		surnames:   make(map[string]struct{}),
+		Context: &Context{
+			Words: []string{"name", "named", "called", "contact", "patient", "customer", "user", "dear", "hi", "hello", "by"},
+			Score: 0.45,
+		},
+	}
+	for _, locale := range locales {
+		if f, err := dictionaryFS.Open("dictionaries/first_names_" + locale + ".txt"); err == nil {
+			_ = d.LoadFirstNames(f)
+			f.Close()
+		}
+		if f, err := dictionaryFS.Open("dictionaries/surnames_" + locale + ".txt"); err == nil {
+			_ = d.LoadSurnames(f)
+			f.Close()
+		}
+	}
+	return d
+}
+
+func (d *NameDetector) Name() string {
+	return d.name
+}
+
+func (d *NameDetector) AddFirstNames(names ...string) {
+	d.mu.Lock()
+	addToSet(d.firstNames, names)
+	d.mu.Unlock()
+}
+
+func (d *NameDetector) AddSurnames(names ...string) {
+	d.mu.Lock()
+	addToSet(d.surnames, names)
+	d.mu.Unlock()
+}
+
+// LoadFirstNames adds the first names of a dictionary read with ReadDictionary.
+func (d *NameDetector) LoadFirstNames(r io.Reader) error {
+	names, err := ReadDictionary(r)
+	if err != nil {
+		return err
+	}
+	d.AddFirstNames(names...)
+	return nil
+}
+
+// LoadSurnames adds the surnames of a dictionary read with ReadDictionary.
+func (d *NameDetector) LoadSurnames(r io.Reader) error {
+	names, err := ReadDictionary(r)
+	if err != nil {
+		return err
+	}
+	d.AddSurnames(names...)
+	return nil
+}
+
+type nameToken struct {
+	word       string
+	start, end int
+}
+
+func (d *NameDetector) Find(text string) []Finding {
+	var tokens []nameToken
+	for _, loc := range nameTokenRegex.FindAllStringIndex(text, -1) {
+		tokens = append(tokens, nameToken{word: text[loc[0]:loc[1]], start: loc[0], end: loc[1]})
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var findings []Finding
+	// The streets are only looked for once an honorific is found.
+	var streets [][]int
+	for i := 0; i < len(tokens); i++ {
+		word := strings.ToLower(strings.TrimSuffix(tokens[i].word, "."))
+		if !isCapitalized(tokens[i].word) {
+			continue
+		}
+		_, honorific := honorifics[word]
+		_, firstName := d.firstNames[word]
+		if !honorific && !firstName {
+			continue
+		}
+		from := i
+		if honorific {
+			from = i + 1
+		}
+		to := from
+		for to+1 < len(tokens) && to+1-from < 4 && d.continuesName(text, tokens[to], tokens[to+1]) {
+			to++
+		}
+		if honorific {
+			if from >= len(tokens) || !d.continuesName(text, tokens[i], tokens[from]) || !isNameWord(tokens[from].word) {
+				continue
+			}
+			if streets == nil {
+				streets = streetSpans(text)
+			}
+			if i > 0 && inStreet(streets, tokens[i-1], tokens[i]) {
+				continue
+			}
+		}
+		f := Finding{Detector: d.name, Start: tokens[from].start, End: tokens[to].end}
+		if last := tokens[to].word; strings.HasSuffix(last, ".") && utf8.RuneCountInString(last) > 2 {
+			f.End--
+		}
+		f.Value = text[f.Start:f.End]
+		switch {
+		case honorific:
+			f.Confidence = 0.9
+		case to > from:
+			f.Confidence = 0.7
+			for _, t := range tokens[from+1 : to+1] {
+				if _, ok := d.surnames[strings.ToLower(strings.TrimSuffix(t.word, "."))]; ok {
+					f.Confidence = 0.9
+					break
+				}
+			}
+		case d.Context != nil:
+			f = d.Context.Apply(text, f)
+		default:
+			f.Confidence = 0.5
+		}
+		findings = append(findings, f)
+		i = to
+	}
+	return findings
+}
+
+// continuesName reports whether next directly follows prev on the same line
+// and can be part of the same name.
+func (d *NameDetector) continuesName(text string, prev, next nameToken) bool {
+	if strings.Trim(text[prev.end:next.start], " \t") != "" || next.start == prev.end {
+		return false
+	}
+	// A name ends at a sentence end, but not at an initial such as "J.".
+	if strings.HasSuffix(prev.word, ".") && utf8.RuneCountInString(prev.word) > 2 {
+		if _, ok := honorifics[strings.ToLower(strings.TrimSuffix(prev.word, "."))]; !ok {
+			return false
+		}
+	}
+	if !isCapitalized(next.word) {
+		return false
+	}
+	_, stop := nameStopWords[strings.ToLower(strings.TrimSuffix(next.word, "."))]
+	return !stop
+}
+
+// isNameWord reports whether a capitalized word can start a name: an
+// initial such as "J." or a word with a lower case letter, unlike "NW".
+func isNameWord(word string) bool {
+	if utf8.RuneCountInString(strings.TrimSuffix(word, ".")) == 1 {
+		return true
+	}
+	return strings.IndexFunc(word, unicode.IsLower) >= 0
+}
+
+// streetSpans returns the locations of the streets of the addresses in text.
+func streetSpans(text string) [][]int {
+	street := AddressRegex.SubexpIndex("street")
+	spans := make([][]int, 0, 1)
+	for _, m := range AddressRegex.FindAllStringSubmatchIndex(text, -1) {
+		spans = append(spans, m[2*street:2*street+2])
+	}
+	return spans
+}
+
+// inStreet reports whether a street holds both a word and the honorific
+// after it, the street type such as "Dr." of "12 Oak Dr.".
+func inStreet(streets [][]int, word, honorific nameToken) bool {
+	for _, span := range streets {
+		if span[0] <= word.start && honorific.end <= span[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func isCapitalized(word string) bool {
+	r, _ := utf8.DecodeRuneInString(word)
+	return unicode.IsUpper(r)
+}
+
+// ReadDictionary reads one entry per line, skipping blank lines and lines
+// starting with "#".
+func ReadDictionary(r io.Reader) ([]string, error) {
+	var entries []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return entries, scanner.Err()
+}
+
+// LoadDictionaryDetector returns a DictionaryDetector over the entries of a
+// dictionary read with ReadDictionary, e.g. a list of customer or company
+// names.
+func LoadDictionaryDetector(name string, r io.Reader, caseSensitive bool) (*DictionaryDetector, error) {
+	entries, err := ReadDictionary(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewDictionaryDetector(name, entries, caseSensitive), nil
+}
+
+func toSet(words []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(words))
+	addToSet(set, words)
+	return set
+}
+
+func addToSet(set map[string]struct{}, words []string) {
+	for _, word := range words {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			set[word] = struct{}{}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package anonymizer
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNameDetector(t *testing.T) {
+	d := NewNameDetector("person")
+	tests := []struct {
+		text       string
+		want       string
+		confidence float64
+	}{
+		{"Please call John Smith today.", "John Smith", 0.9},
+		{"Signed by Maria Garcia.", "Maria Garcia", 0.9},
+		{"John Foo signed it", "John Foo", 0.7},
+		{"John J. Smith wrote", "John J. Smith", 0.9},
+		{"Dr. Foo Bar arrived", "Foo Bar", 0.9},
+		{"Hi John, welcome", "John", 0.8},
+		{"John was late", "John", 0.35},
+		{"Ram Sharma from Kathmandu", "Ram Sharma", 0.9},
+		{"Hans\nMüller", "Hans", 0.35},
+		{"john smith", "", 0},
+		{"Dr. smith", "", 0},
+		{"Dr. NW", "", 0},
+		{"12 Oak Dr. Springfield", "", 0},
+		{"Mail it to 12 Oak Dr. Springfield, IL 62704", "", 0},
+		{"Room 12 Dr. Foo Bar", "Foo Bar", 0.9},
+		{"Dr. J. Foo arrived", "J. Foo", 0.9},
+		{"The Report", "", 0},
+	}
+	for _, tt := range tests {
+		findings := d.Find(tt.text)
+		if tt.want == "" {
+			if len(findings) > 0 {
+				t.Errorf("Find(%q) = %+v", tt.text, findings)
+			}
+			continue
+		}
+		if len(findings) != 1 {
+			t.Errorf("Find(%q) = %+v, want %q", tt.text, findings, tt.want)
+			continue
+		}
+		f := findings[0]
+		if f.Value != tt.want || tt.text[f.Start:f.End] != tt.want || math.Abs(f.Confidence-tt.confidence) > 1e-9 {
+			t.Errorf("Find(%q) = %+v, want %q with confidence %v", tt.text, f, tt.want, tt.confidence)
+		}
+	}
+}
+
+func TestNameDetectorLocales(t *testing.T) {
+	en := NewNameDetector("person", "en")
+	if got := en.Find("Sita Foo"); len(got) > 0 {
+		t.Errorf("en detector found %+v", got)
+	}
+	ne := NewNameDetector("person", "ne")
+	if got := findingKeys(ne.Find("Sita Foo")); !reflect.DeepEqual(got, []string{"person[0:8]"}) {
+		t.Errorf("ne detector found %v", got)
+	}
+	en.AddFirstNames("Zorro")
+	en.AddSurnames("Vega")
+	if got := en.Find("Zorro Vega"); len(got) != 1 || got[0].Confidence != 0.9 {
+		t.Errorf("Find with added names = %+v", got)
+	}
+	if err := en.LoadFirstNames(strings.NewReader("# first names\n\nSita\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := findingKeys(en.Find("Sita Foo")); !reflect.DeepEqual(got, []string{"person[0:8]"}) {
+		t.Errorf("Find with loaded names = %v", got)
+	}
+}
+
+func TestReadDictionary(t *testing.T) {
+	got, err := ReadDictionary(strings.NewReader("# customers\nAcme Widgets\n\n  Globex  \n#Initech\n"))
+	if err != nil || !reflect.DeepEqual(got, []string{"Acme Widgets", "Globex"}) {
+		t.Errorf("ReadDictionary = %q, %v", got, err)
+	}
+	d, err := LoadDictionaryDetector("customer", strings.NewReader("Acme Widgets\nGlobex\n"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := findingKeys(d.Find("orders from ACME widgets and Globexx")); !reflect.DeepEqual(got, []string{"customer[12:24]"}) {
+		t.Errorf("Find = %v", got)
+	}
+}
+
+func TestParseOrganizations(t *testing.T) {
+	text := "She left Acme Widgets Inc. for Deutsche Bank, then Tata Consultancy Pvt Ltd in 2020."
+	want := []string{"Acme Widgets Inc.", "Deutsche Bank", "Tata Consultancy Pvt Ltd"}
+	if got := ParseOrganizations(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseOrganizations = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+package anonymizer
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PhoneNumber is a phone number split into its country calling code and its
+// national significant number.
+type PhoneNumber struct {
+	Country     string `json:"country"`
+	CountryCode string `json:"country_code"`
+	National    string `json:"national"`
+}
+
+// E164 formats the number as +<country code><national number>.
+func (p PhoneNumber) E164() string {
+	return "+" + p.CountryCode + p.National
+}
+
+// PhoneMetadata describes the numbering plan of a country: its calling
+// code, the trunk prefix dialled before national numbers, the pattern of
+// valid national significant numbers and the pattern of those customarily
+// written without the trunk prefix, such as mobile numbers.
+type PhoneMetadata struct {
+	CountryCode string
+	Trunk       string
+	National    *regexp.Regexp
+	Bare        *regexp.Regexp
+}
+
+// PhoneMetadataMap holds the numbering plans known to ParsePhoneNumber,
+// keyed by ISO 3166 alpha-2 country code.
+var PhoneMetadataMap = map[string]PhoneMetadata{
+	"NP": {CountryCode: "977", Trunk: "0", National: regexp.MustCompile(`^(?:9[678]\d{8}|[1-9]\d{6,7})$`), Bare: regexp.MustCompile(`^9[678]\d{8}$`)},
+	"IN": {CountryCode: "91", Trunk: "0", National: regexp.MustCompile(`^[1-9]\d{9}$`), Bare: regexp.MustCompile(`^[6-9]\d{9}$`)},
+	"GB": {CountryCode: "44", Trunk: "0", National: regexp.MustCompile(`^[1-9]\d{8,9}$`)},
+	"DE": {CountryCode: "49", Trunk: "0", National: regexp.MustCompile(`^[1-9]\d{5,12}$`)},
+	"FR": {CountryCode: "33", Trunk: "0", National: regexp.MustCompile(`^[1-9]\d{8}$`)},
+	"ES": {CountryCode: "34", National: regexp.MustCompile(`^[6-9]\d{8}$`)},
+	"US": {CountryCode: "1", Trunk: "1", National: regexp.MustCompile(`^[2-9]\d{2}[2-9]\d{6}$`), Bare: regexp.MustCompile(`^[2-9]\d{2}[2-9]\d{6}$`)},
+}
+
+var phoneFormatReplacer = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "", "/", "", "\t", "")
+
+// PhoneCandidateRegex matches digit runs that may be phone numbers in
+// international or national format.
+var PhoneCandidateRegex = regexp.MustCompile(`(?:\+|\b00)?\(?\d[\d \-.()/]{5,18}\d\b`)
+
+// ParsePhoneNumber parses a number in international format (+ or 00
+// followed by the calling code), or in the national format of
+// defaultCountry.
+func ParsePhoneNumber(number, defaultCountry string) (PhoneNumber, error) {
+	digits := phoneFormatReplacer.Replace(strings.TrimSpace(number))
+	international := false
+	if strings.HasPrefix(digits, "+") {
+		digits, international = digits[1:], true
+	} else if strings.HasPrefix(digits, "00") {
+		digits, international = digits[2:], true
+	}
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return PhoneNumber{}, errors.New("phone number contains non-digits")
+	}
+	if international {
+		// Calling codes are prefix-free, so at most one of them matches.
+		for country, meta := range PhoneMetadataMap {
+			national, ok := strings.CutPrefix(digits, meta.CountryCode)
+			if ok && meta.National.MatchString(national) {
+				return PhoneNumber{Country: country, CountryCode: meta.CountryCode, National: national}, nil
+			}
+		}
+		return PhoneNumber{}, fmt.Errorf("invalid international phone number %q", number)
+	}
+	country := strings.ToUpper(defaultCountry)
+	meta, ok := PhoneMetadataMap[country]
+	if !ok {
+		return PhoneNumber{}, fmt.Errorf("unknown country %q", defaultCountry)
+	}
+	national := digits
+	if meta.Trunk != "" && !meta.National.MatchString(national) {
+		national = strings.TrimPrefix(national, meta.Trunk)
+	}
+	if !meta.National.MatchString(national) {
+		return PhoneNumber{}, fmt.Errorf("invalid %s phone number %q", country, number)
+	}
+	return PhoneNumber{Country: country, CountryCode: meta.CountryCode, National: national}, nil
+}
+
+// NewPhoneDetector returns a detector of the phone numbers of a country,
+// written in international format or in national format. Since any digit
+// run parses as some national number, the latter must start with the trunk
+// prefix unless it matches PhoneMetadata.Bare.
+func NewPhoneDetector(name, country string) *RegexDetector {
+	country = strings.ToUpper(country)
+	return NewRegexDetector(name, PhoneCandidateRegex).WithValidator(func(value string) bool {
+		p, err := ParsePhoneNumber(value, country)
+		if err != nil || p.Country != country {
+			return false
+		}
+		digits := phoneFormatReplacer.Replace(value)
+		if strings.HasPrefix(digits, "+") || strings.HasPrefix(digits, "00") {
+			return true
+		}
+		meta := PhoneMetadataMap[country]
+		if meta.Trunk == "" || (meta.Bare != nil && meta.Bare.MatchString(digits)) {
+			return true
+		}
+		return strings.HasPrefix(digits, meta.Trunk) && digits != p.National
+	})
+}
@@ -0,0 +1,206 @@
+package anonymizer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PlaceholderConverter converts the text captured by a typed placeholder,
+// given the argument of the placeholder such as the layout in
+// <when:date:02/01/2006>.
+type PlaceholderConverter func(value, arg string) (any, error)
+
+// PlaceholderType is the type of placeholders such as <id:int>: the pattern
+// of the values they capture and the converter of these values.
+//
+// Time types set Layout. Given a layout as argument, such as in
+// <when:date:02/01/2006>, or a DefaultLayout, their pattern is derived from
+// the layout and their values are converted to times. Without either, they
+// capture strings matching Pattern.
+type PlaceholderType struct {
+	Pattern       string
+	Layout        bool
+	DefaultLayout string
+	Convert       PlaceholderConverter
+}
+
+// PlaceholderTypes holds the types of placeholders converted by Parse.
+// Placeholders of other types, such as <site:link>, take their pattern from
+// PatternsMap, or StringPattern when it has none, and capture strings;
+// <code:/[A-Z]{3}\d+/> captures strings matching an inline regular
+// expression.
+var PlaceholderTypes = map[string]PlaceholderType{
+	"int":      {Pattern: `[-+]?\d+`, Convert: convertInt},
+	"integer":  {Pattern: `[-+]?\d+`, Convert: convertInt},
+	"float":    {Pattern: `[-+]?(?:\d+(?:\.\d*)?|\.\d+)(?:[eE][-+]?\d+)?`, Convert: convertFloat},
+	"bool":     {Pattern: `(?i:true|false|t|f|1|0)`, Convert: convertBool},
+	"date":     {Pattern: DatePattern, Layout: true, Convert: convertTime},
+	"time":     {Pattern: TimePattern, Layout: true, Convert: convertTime},
+	"datetime": {Layout: true, DefaultLayout: time.RFC3339, Convert: convertTime},
+	"ip":       {Pattern: IPv4Pattern + `|[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}(?::` + IPv4Pattern + `)?`, Convert: convertIP},
+	"email":    {Pattern: EmailPattern, Convert: convertEmail},
+}
+
+// RegisterPlaceholderType adds a placeholder type capturing values matching
+// pattern and converted by convert.
+func RegisterPlaceholderType(name, pattern string, convert PlaceholderConverter) error {
+	if len(name) == 0 {
+		return errors.New("placeholder type name is null")
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("placeholder type %q: %w", name, err)
+	}
+	PlaceholderTypes[name] = PlaceholderType{Pattern: pattern, Convert: convert}
+	return nil
+}
+
+// placeholder is a parsed input placeholder.
+type placeholder struct {
+	name    string
+	pattern string
+	arg     string
+	layout  bool
+	convert PlaceholderConverter
+}
+
+// parsePlaceholder parses a placeholder of the form <name>, <name:type>,
+// <name:type:arg> or <name:/regexp/>.
+func parsePlaceholder(inString string) (placeholder, error) {
+	matches := PlaceHolderRegex.FindStringSubmatch(inString)
+	if matches == nil {
+		return placeholder{}, fmt.Errorf("invalid placeholder %q", inString)
+	}
+	p := placeholder{name: matches[1], pattern: StringPattern, arg: matches[5]}
+	switch typ := matches[4]; {
+	case typ == "":
+	case strings.HasPrefix(typ, "/"):
+		p.pattern = strings.ReplaceAll(typ[1:len(typ)-1], `\/`, `/`)
+		if _, err := regexp.Compile(p.pattern); err != nil {
+			return placeholder{}, fmt.Errorf("placeholder %q: %w", inString, err)
+		}
+	default:
+		if t, ok := PlaceholderTypes[typ]; ok {
+			p.pattern, p.convert = t.Pattern, t.Convert
+			if t.Layout {
+				if p.arg == "" {
+					p.arg = t.DefaultLayout
+				}
+				if p.arg != "" {
+					p.pattern, p.layout = layoutPattern(p.arg), true
+				} else {
+					p.convert = nil
+				}
+			}
+		} else if pattern, ok := PatternsMap[typ]; ok {
+			p.pattern = pattern
+		}
+	}
+	p.pattern = unnamedGroups(p.pattern)
+	return p, nil
+}
+
+// unnamedGroups returns pattern with its named groups, such as those of
+// AddressPattern, left unnamed, so that a placeholder only captures its own
+// value and a template may use the same type twice.
+func unnamedGroups(pattern string) string {
+	if !strings.Contains(pattern, "(?P<") && !strings.Contains(pattern, "(?<") {
+		return pattern
+	}
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return pattern
+	}
+	var walk func(re *syntax.Regexp)
+	walk = func(re *syntax.Regexp) {
+		re.Name = ""
+		for _, sub := range re.Sub {
+			walk(sub)
+		}
+	}
+	walk(re)
+	return re.String()
+}
+
+// group returns the capture group of the placeholder.
+func (p placeholder) group() string {
+	return fmt.Sprintf("(?P<%s>%s)", p.name, p.pattern)
+}
+
+func convertInt(value, _ string) (any, error) {
+	return strconv.Atoi(strings.TrimPrefix(value, "+"))
+}
+
+func convertFloat(value, _ string) (any, error) {
+	return strconv.ParseFloat(value, 64)
+}
+
+func convertBool(value, _ string) (any, error) {
+	return strconv.ParseBool(strings.ToLower(value))
+}
+
+func convertIP(value, _ string) (any, error) {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip address %q", value)
+	}
+	return ip, nil
+}
+
+func convertEmail(value, _ string) (any, error) {
+	if !IsValidEmail(value) {
+		return nil, fmt.Errorf("invalid email address %q", value)
+	}
+	return value, nil
+}
+
+func convertTime(value, layout string) (any, error) {
+	return time.Parse(layout, value)
+}
+
+var layoutTokens = []struct{ token, pattern string }{
+	{"January", `[A-Za-z]+`}, {"Jan", `[A-Za-z]{3}`}, {"Monday", `[A-Za-z]+`}, {"Mon", `[A-Za-z]{3}`},
+	{"2006", `\d{4}`}, {"002", `\d{3}`}, {"_2", `[ \d]\d`}, {"06", `\d{2}`}, {"01", `\d{2}`}, {"02", `\d{2}`},
+	{"15", `\d{2}`}, {"03", `\d{2}`}, {"04", `\d{2}`}, {"05", `\d{2}`},
+	{"1", `\d{1,2}`}, {"2", `\d{1,2}`}, {"3", `\d{1,2}`}, {"4", `\d{1,2}`}, {"5", `\d{1,2}`},
+	{"PM", `[AP]M`}, {"pm", `[ap]m`}, {"MST", `(?:[A-Z]{3,5}|[-+]\d{2,4})`},
+	{"Z07:00", `(?:Z|[-+]\d{2}:\d{2})`}, {"Z0700", `(?:Z|[-+]\d{4})`}, {"Z07", `(?:Z|[-+]\d{2})`},
+	{"-07:00", `[-+]\d{2}:\d{2}`}, {"-0700", `[-+]\d{4}`}, {"-07", `[-+]\d{2}`},
+}
+
+// layoutPattern returns the pattern of the times formatted with a layout.
+func layoutPattern(layout string) string {
+	var b strings.Builder
+next:
+	for i := 0; i < len(layout); {
+		for _, t := range layoutTokens {
+			if strings.HasPrefix(layout[i:], t.token) {
+				b.WriteString(t.pattern)
+				i += len(t.token)
+				continue next
+			}
+		}
+		// Fractional seconds, such as ".000" or ",999".
+		if c := layout[i]; (c == '.' || c == ',') && i+1 < len(layout) && (layout[i+1] == '0' || layout[i+1] == '9') {
+			j := i + 1
+			for j < len(layout) && layout[j] == layout[i+1] {
+				j++
+			}
+			if layout[i+1] == '0' {
+				b.WriteString(`[.,]\d{` + strconv.Itoa(j-i-1) + `}`)
+			} else {
+				b.WriteString(`(?:[.,]\d+)?`)
+			}
+			i = j
+			continue
+		}
+		b.WriteString(regexp.QuoteMeta(layout[i : i+1]))
+		i++
+	}
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package anonymizer
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePlaceholderTypes(t *testing.T) {
+	tests := []struct {
+		data, pattern string
+		want          map[string]any
+	}{
+		{"on 12/01/2020 at 10:30 pm", "on <d:date> at <t:time>", map[string]any{"d": "12/01/2020", "t": "10:30 pm"}},
+		{"on 12/01/2020 at 10:30", "on <d:date:02/01/2006> at <t:time:15:04>", map[string]any{
+			"d": time.Date(2020, time.January, 12, 0, 0, 0, 0, time.UTC),
+			"t": time.Date(0, time.January, 1, 10, 30, 0, 0, time.UTC),
+		}},
+		{"at 2020-01-02T10:00:00Z.", "at <w:datetime>.", map[string]any{"w": time.Date(2020, time.January, 2, 10, 0, 0, 0, time.UTC)}},
+		{"id +42 of 1.5 ok true", "id <n:int> of <f:float> ok <b:bool>", map[string]any{"n": 42, "f": 1.5, "b": true}},
+		{"ip 10.1.2.3", "ip <a:ip>", map[string]any{"a": net.ParseIP("10.1.2.3")}},
+		{"code ABC123!", `code <c:/[A-Z]{3}\d+/>!`, map[string]any{"c": "ABC123"}},
+		{"name bob", "name <x:unknown>", map[string]any{"x": "bob"}},
+		{"from 12 Oak St to 9 Elm Ave.", "from <a:address> to <b:address>.", map[string]any{"a": "12 Oak St", "b": "9 Elm Ave"}},
+		{"at 27.7172, 85.3240", "at <c:coordinates>", map[string]any{"c": "27.7172, 85.3240"}},
+		{"code 12", `code <c:/(?P<digit>\d)+/>`, map[string]any{"c": "12"}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.data, tt.pattern)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q, %q) = %#v, %v, want %#v", tt.data, tt.pattern, got, err, tt.want)
+		}
+	}
+}
+
+func TestParsePlaceholderErrors(t *testing.T) {
+	if _, err := Parse("id x1", "id <n:/x(/>"); err == nil {
+		t.Error("invalid inline regular expression compiled")
+	}
+	if _, err := Parse("mail nobody", "mail <m:email>"); err == nil {
+		t.Error("mismatch succeeded")
+	}
+}
+
+func TestTemplateReplaceLayout(t *testing.T) {
+	tmpl, err := CompileTemplate("due <d:date:02/01/2006>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tmpl.Replace("pay due 12/01/2020 now", map[string]any{"d": time.Date(2021, time.March, 4, 0, 0, 0, 0, time.UTC)})
+	if want := "pay due 04/03/2021 now"; err != nil || got != want {
+		t.Errorf("Replace = %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestRegisterPlaceholderType(t *testing.T) {
+	defer delete(PlaceholderTypes, "sku")
+	if err := RegisterPlaceholderType("sku", `SKU-\d+`, func(value, _ string) (any, error) {
+		return value[4:], nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Parse("item SKU-77", "item <s:sku>")
+	if err != nil || got["s"] != "77" {
+		t.Errorf("Parse = %#v, %v", got, err)
+	}
+	if err := RegisterPlaceholderType("bad", `(`, nil); err == nil {
+		t.Error("invalid pattern registered")
+	}
+}
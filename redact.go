@@ -0,0 +1,140 @@
+package anonymizer
+
+import (
+	"strings"
+)
+
+// Policy describes how free text is redacted: which detectors run and how
+// their findings are replaced.
+type Policy struct {
+	// Registry the detectors come from; DefaultRegistry when nil.
+	Registry *Registry `json:"-"`
+	// Detectors to run; all registered detectors when empty.
+	Detectors []string `json:"detectors"`
+	// Locales names the locale packs whose detectors run as well.
+	Locales []string `json:"locales"`
+	// Rules pick the replacer for a detector through Rule.Field.
+	Rules []Rule `json:"rules"`
+	// Replacement is used for findings without a rule. Findings are
+	// replaced by the upper-cased detector name in brackets when empty.
+	Replacement string `json:"replacement"`
+	// Allow holds values never redacted, Deny values always redacted,
+	// whichever detector is concerned. Rules hold per detector lists.
+	Allow *List `json:"allow,omitempty"`
+	Deny  *List `json:"deny,omitempty"`
+}
+
+// DenyListDetector names the findings of denied values no detector reported.
+const DenyListDetector = "denylist"
+
+func (p Policy) registry() *Registry {
+	if p.Registry != nil {
+		return p.Registry
+	}
+	return defaultRegistry()
+}
+
+// detectors returns the detectors the policy runs, those of its locale
+// packs included.
+func (p Policy) detectors() []Detector {
+	detectors := p.registry().Detectors(p.Detectors...)
+	for _, name := range p.Locales {
+		if pack, ok := GetLocalePack(name); ok {
+			detectors = append(detectors, pack.Detectors...)
+		}
+	}
+	return detectors
+}
+
+// Find returns the findings of the policy's detectors reaching
+// MinConfidence, without the allowed values and with the denied ones.
+func (p Policy) Find(text string) []Finding {
+	var findings []Finding
+	for _, f := range p.find(text) {
+		if p.allowed(f) {
+			continue
+		}
+		findings = append(findings, f)
+	}
+	denied := p.Deny.Find(text, DenyListDetector)
+	for _, rule := range p.Rules {
+		name := rule.Field
+		if name == "" {
+			name = DenyListDetector
+		}
+		denied = append(denied, rule.Deny.Find(text, name)...)
+	}
+	return mergeFindings(findings, denied)
+}
+
+func (p Policy) find(text string) []Finding {
+	r := p.registry()
+	if len(p.Locales) == 0 {
+		return r.Find(text, p.Detectors...)
+	}
+	// Unknown packs are skipped like unknown detector names.
+	names := append([]string(nil), p.Detectors...)
+	if len(names) == 0 {
+		names = r.Names()
+	}
+	for _, name := range p.Locales {
+		if pack, ok := GetLocalePack(name); ok {
+			for _, d := range pack.Detectors {
+				names = append(names, d.Name())
+			}
+		}
+	}
+	return r.find(text, r.localeScanner(p.Detectors, p.Locales), names)
+}
+
+func (p Policy) allowed(f Finding) bool {
+	if p.Deny.Contains(f.Value) {
+		return false
+	}
+	if p.Allow.Contains(f.Value) {
+		return true
+	}
+	for _, rule := range p.Rules {
+		if rule.allows(f.Detector, f.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact replaces every finding of the policy in text.
+func Redact(text string, policy Policy) string {
+	return policy.redact(text, policy.Find(text))
+}
+
+// redact rewrites text with the findings replaced. Findings overlapping an
+// earlier, or at the same position a longer, finding are skipped.
+func (p Policy) redact(text string, findings []Finding) string {
+	var sb strings.Builder
+	last := 0
+	for _, f := range findings {
+		if f.Start < last {
+			continue
+		}
+		sb.WriteString(text[last:f.Start])
+		sb.WriteString(p.replacement(f))
+		last = f.End
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+func (p Policy) replacement(f Finding) string {
+	for _, rule := range p.Rules {
+		if !rule.applies(f.Detector, f.Value) {
+			continue
+		}
+		if ruler, ok := lookupReplacer(rule.Type); ok {
+			return replaceString(ruler, f.Value, rule.Value)
+		}
+	}
+	if p.Replacement != "" {
+		return p.Replacement
+	}
+	return "[" + strings.ToUpper(f.Detector) + "]"
+}
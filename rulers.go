@@ -2,8 +2,10 @@ package anonymizer
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/brianvoe/gofakeit/v6"
 	"hash/maphash"
 	"math/rand"
@@ -15,6 +17,12 @@ type Replacer interface {
 	Replace(any, string) any
 }
 
+// compositeReplacer is implemented by replacers that replace some maps,
+// slices or arrays as a whole rather than the values inside them.
+type compositeReplacer interface {
+	replacesComposite(val reflect.Value) bool
+}
+
 type Asterisk struct {
 	Symbol string `json:"symbol"`
 }
@@ -25,13 +33,13 @@ func (a *Asterisk) Replace(source any, name string) any {
 	}
 	switch field := source.(type) {
 	case reflect.Value:
-		v := []rune(field.String())
+		v := []rune(valueString(field))
 		masked := make([]string, len(v))
 		for idx := range masked {
 			masked[idx] = "*"
 		}
 		m := strings.Join(masked, "")
-		if field.CanSet() {
+		if field.CanSet() && field.Kind() == reflect.String {
 			field.Set(reflect.ValueOf(m))
 		} else {
 			return m
@@ -62,7 +70,7 @@ func (a *Hasher) Replace(source any, name string) any {
 	switch field := source.(type) {
 	case reflect.Value:
 		h := sha256.New()
-		h.Write([]byte(field.String()))
+		h.Write([]byte(valueString(field)))
 		return hex.EncodeToString(h.Sum(nil))
 	default:
 		return source
@@ -79,7 +87,7 @@ func (a *Encrypter) Replace(source any, name string) any {
 	}
 	switch field := source.(type) {
 	case reflect.Value:
-		encrypted, _ := Encrypt(field.String(), a.Secret)
+		encrypted, _ := Encrypt(valueString(field), a.Secret)
 		return encrypted
 	default:
 		return source
@@ -120,6 +128,12 @@ var rulerBuiltinLookup = map[string]Replacer{
 	"empty":    &Empty{},
 	"hash":     &Hasher{},
 	"encrypt":  &Encrypter{},
+	"address":  &AddressAnonymizer{},
+	"geo":      &Geo{},
+	"ip":       &IPAnonymizer{},
+	"email":    &EmailAnonymizer{},
+	"url":      &URLRedactor{},
+	"template": &TemplateAnonymizer{},
 }
 var rulerCustomLookup = map[string]Replacer{}
 
@@ -143,3 +157,38 @@ func RemoveCustomReplacer(name string) error {
 	}
 	return nil
 }
+
+func lookupReplacer(name string) (Replacer, bool) {
+	if ruler, ok := rulerBuiltinLookup[name]; ok {
+		return ruler, true
+	}
+	ruler, ok := rulerCustomLookup[name]
+	return ruler, ok
+}
+
+// replaceString runs a replacer on a plain string value.
+func replaceString(ruler Replacer, value, param string) string {
+	switch v := ruler.Replace(reflect.ValueOf(value), param).(type) {
+	case reflect.Value:
+		return fmt.Sprint(v.Interface())
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// indirect looks through the interfaces and pointers holding a value.
+func indirect(val reflect.Value) reflect.Value {
+	for (val.Kind() == reflect.Interface || val.Kind() == reflect.Ptr) && !val.IsNil() {
+		val = val.Elem()
+	}
+	return val
+}
+
+// seededFaker returns a faker seeded from value, so that the same value is
+// always replaced by the same fake data.
+func seededFaker(value, salt string) *gofakeit.Faker {
+	sum := sha256.Sum256([]byte(salt + value))
+	return gofakeit.New(int64(binary.BigEndian.Uint64(sum[:8])))
+}
@@ -0,0 +1,400 @@
+package anonymizer
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var (
+	DefaultScanChunkSize = 64 << 10
+	// DefaultScanOverlap is how far past its end a chunk is scanned so that
+	// matches starting near the boundary are found whole.
+	DefaultScanOverlap = 4096
+)
+
+// AnchorMap holds literals, matched case-insensitively, of which at least one
+// occurs in every match of a built-in pattern. Such patterns only run around
+// their anchors, or on the chunks holding one; see Scanner.
+var AnchorMap = map[string][]string{
+	"date":                      append(digitAnchors("-", ".", "/"), "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"),
+	"time":                      digitAnchors(":", "a", "p"),
+	"email":                     {"@"},
+	"link":                      linkAnchors(),
+	"ip":                        append(digitAnchors("."), hexColonAnchors()...),
+	"ip4":                       digitAnchors("."),
+	"ip6":                       hexColonAnchors(),
+	"mac_address":               {":", "-"},
+	"po_box":                    {"box"},
+	"git_repo":                  {".git"},
+	"cloudinary_url":            {"cloudinary://"},
+	"firebase_url":              {"firebaseio.com"},
+	"slack_token":               {"xox"},
+	"rsa_private_key":           {"-----BEGIN"},
+	"dsa_private_key":           {"-----BEGIN"},
+	"ec_private_key":            {"-----BEGIN"},
+	"pgp_private_key":           {"-----BEGIN"},
+	"pem_block":                 {"-----BEGIN"},
+	"jwt":                       {"eyJ"},
+	"bearer_token":              {"authorization"},
+	"aws_access_key":            {"AKIA"},
+	"mws_token":                 {"amzn.mws."},
+	"facebook_token":            {"EAACEdEose0cBA"},
+	"facebook_oauth":            {"facebook"},
+	"github_token":              {"github"},
+	"api_key":                   {"api"},
+	"secret":                    {"secret"},
+	"google_api_key":            {"AIza"},
+	"gcp_oauth":                 {".apps.googleusercontent.com"},
+	"google_oauth_token":        {"ya29."},
+	"heroku_api_key":            {"heroku"},
+	"mailchimp_api_key":         {"-us"},
+	"mailgun_api_key":           {"key-"},
+	"password_in_url":           {"://"},
+	"braintree_token":           {"access_token$production$"},
+	"picatic_api_key":           {"sk_live_"},
+	"slack_webhook":             {"https://hooks.slack.com/services/"},
+	"stripe_api_key":            {"sk_live_"},
+	"stripe_restricted_api_key": {"rk_live_"},
+	"square_access_token":       {"sq0atp-"},
+	"square_oauth_secret":       {"sq0csp-"},
+	"twilio_api_key":            {"SK"},
+	"twitter_access_token":      {"twitter"},
+	"twitter_oauth":             {"twitter"},
+}
+
+// digitAnchors returns every digit followed by each of the separators.
+func digitAnchors(separators ...string) []string {
+	anchors := make([]string, 0, 10*len(separators))
+	for _, sep := range separators {
+		for d := '0'; d <= '9'; d++ {
+			anchors = append(anchors, string(d)+sep)
+		}
+	}
+	return anchors
+}
+
+// hexColonAnchors returns the literals one of which occurs in every IPv6
+// address: a hexadecimal digit before a colon, or an elision.
+func hexColonAnchors() []string {
+	return append(digitAnchors(":"), "a:", "b:", "c:", "d:", "e:", "f:", "::")
+}
+
+// linkAnchors returns the literals one of which occurs in every link: a
+// scheme separator, an IPv6 address, a digit before a dot, starting an IPv4
+// address, or a dot before a letter or a non-ASCII character, starting a
+// top-level domain. Unlike a lone ".", they are not found at the end of
+// sentences.
+func linkAnchors() []string {
+	anchors := append([]string{"://", "["}, hexColonAnchors()...)
+	anchors = append(anchors, digitAnchors(".")...)
+	for c := 'a'; c <= 'z'; c++ {
+		anchors = append(anchors, "."+string(c))
+	}
+	// Leading bytes of multi-byte UTF-8 sequences.
+	for b := 0xc2; b <= 0xf4; b++ {
+		anchors = append(anchors, "."+string([]byte{byte(b)}))
+	}
+	return anchors
+}
+
+// Scanner runs a set of detectors over a text in a single pass. An
+// Aho–Corasick scan locates the anchors of the regex detectors that have
+// some, and their regex only runs on the whitespace-delimited token or the
+// line around each anchor, whichever its pattern cannot match across, or on
+// the chunks holding an anchor when its pattern may match across lines.
+// Large texts are split into chunks scanned in parallel.
+type Scanner struct {
+	ChunkSize int
+	Overlap   int
+	Workers   int
+
+	plain []Detector
+	// gates holds, for the plain regex detectors with anchors, their index
+	// in gated, whose anchors gateMatcher finds.
+	gates       map[*RegexDetector]int
+	gated       int
+	gateOwners  []int
+	gateMatcher *ahoCorasick
+	// extended holds, for the plain regex detectors whose matches have no
+	// bounded length, the detector anchored at the start of the text, to
+	// find whole the matches cut at the end of a chunk's overlap.
+	extended map[*RegexDetector]*RegexDetector
+	anchored []anchoredDetector
+	anchors  []string
+	owners   []int
+	matcher  *ahoCorasick
+}
+
+type anchoredDetector struct {
+	*RegexDetector
+	scope matchScope
+}
+
+// matchScope is the widest piece of text a match of a pattern can span.
+type matchScope int
+
+const (
+	scopeText matchScope = iota
+	scopeLine
+	scopeToken
+)
+
+func NewScanner(detectors ...Detector) *Scanner {
+	s := &Scanner{
+		ChunkSize: DefaultScanChunkSize,
+		Overlap:   DefaultScanOverlap,
+		Workers:   runtime.GOMAXPROCS(0),
+	}
+	var gateAnchors []string
+	for _, d := range detectors {
+		rd, ok := d.(*RegexDetector)
+		if !ok {
+			s.plain = append(s.plain, d)
+			continue
+		}
+		scope := scopeOf(rd.Regex.String())
+		if len(rd.Anchors) == 0 || scope == scopeText {
+			s.plain = append(s.plain, d)
+			if len(rd.Anchors) > 0 {
+				if s.gates == nil {
+					s.gates = make(map[*RegexDetector]int)
+				}
+				s.gates[rd] = s.gated
+				for _, anchor := range rd.Anchors {
+					gateAnchors = append(gateAnchors, anchor)
+					s.gateOwners = append(s.gateOwners, s.gated)
+				}
+				s.gated++
+			}
+			if rd.MaxLen() < 0 && rd.Group == 0 {
+				if s.extended == nil {
+					s.extended = make(map[*RegexDetector]*RegexDetector)
+				}
+				extended := *rd
+				extended.Regex = regexp.MustCompile(`^(?:` + rd.Regex.String() + `)`)
+				s.extended[rd] = &extended
+			}
+			continue
+		}
+		for _, anchor := range rd.Anchors {
+			s.anchors = append(s.anchors, anchor)
+			s.owners = append(s.owners, len(s.anchored))
+		}
+		s.anchored = append(s.anchored, anchoredDetector{RegexDetector: rd, scope: scope})
+	}
+	if len(s.anchors) > 0 {
+		s.matcher = newAhoCorasick(s.anchors)
+	}
+	if len(gateAnchors) > 0 {
+		s.gateMatcher = newAhoCorasick(gateAnchors)
+	}
+	return s
+}
+
+// Scan returns the findings of every detector ordered by position.
+func (s *Scanner) Scan(text string) []Finding {
+	chunks := s.chunks(text)
+	results := make([][]Finding, len(chunks))
+	if len(chunks) == 1 {
+		results[0] = s.scanChunk(text, chunks[0][0], chunks[0][1])
+	} else {
+		workers := s.Workers
+		if workers > len(chunks) {
+			workers = len(chunks)
+		}
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					results[i] = s.scanChunk(text, chunks[i][0], chunks[i][1])
+				}
+			}()
+		}
+		for i := range chunks {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+	}
+	var findings []Finding
+	for _, r := range results {
+		findings = append(findings, r...)
+	}
+	sortFindings(findings)
+	if len(chunks) == 1 {
+		return findings
+	}
+	// Drop what a chunk found inside a match the previous chunk already
+	// reported through its overlap.
+	ends := make(map[string]int)
+	kept := findings[:0]
+	for _, f := range findings {
+		if f.Start < ends[f.Detector] {
+			continue
+		}
+		ends[f.Detector] = f.End
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// chunks splits text at line boundaries into pieces of about ChunkSize bytes.
+func (s *Scanner) chunks(text string) [][2]int {
+	if s.Workers <= 1 || s.ChunkSize <= 0 || len(text) <= s.ChunkSize {
+		return [][2]int{{0, len(text)}}
+	}
+	var chunks [][2]int
+	for start := 0; start < len(text); {
+		end := start + s.ChunkSize
+		if end >= len(text) {
+			end = len(text)
+		} else if nl := strings.IndexByte(text[end:], '\n'); nl >= 0 {
+			end += nl + 1
+		} else {
+			end = len(text)
+		}
+		chunks = append(chunks, [2]int{start, end})
+		start = end
+	}
+	return chunks
+}
+
+// scanChunk reports the findings starting in text[from:to].
+func (s *Scanner) scanChunk(text string, from, to int) []Finding {
+	limit := to
+	if to < len(text) {
+		limit = to + s.Overlap
+		if limit > len(text) {
+			limit = len(text)
+		}
+	}
+	var findings []Finding
+	keep := func(found []Finding, offset int, scored *Context) {
+		for _, f := range found {
+			f.Start += offset
+			f.End += offset
+			if f.Start >= to {
+				continue
+			}
+			if scored != nil {
+				f = scored.Apply(text, f)
+			}
+			findings = append(findings, f)
+		}
+	}
+	window := text[from:limit]
+	var open []bool
+	if s.gateMatcher != nil {
+		// A match starting in the chunk may hold its anchor in the overlap.
+		open = make([]bool, s.gated)
+		s.gateMatcher.findAll(window, func(pattern, end int) {
+			open[s.gateOwners[pattern]] = true
+		})
+	}
+	for _, d := range s.plain {
+		if rd, ok := d.(*RegexDetector); ok {
+			if gate, ok := s.gates[rd]; ok && !open[gate] {
+				continue
+			}
+			found := rd.match(window)
+			if extended := s.extended[rd]; extended != nil && limit < len(text) {
+				for i, f := range found {
+					if longer := extended.match(text[from+f.Start:]); len(longer) > 0 && longer[0].End > f.End-f.Start {
+						found[i].End = f.Start + longer[0].End
+						found[i].Value = longer[0].Value
+					}
+				}
+			}
+			keep(found, from, rd.Context)
+		} else {
+			keep(d.Find(window), from, nil)
+		}
+	}
+	if s.matcher == nil {
+		return findings
+	}
+	// Running a pattern on the token or line around an anchor finds exactly
+	// the matches of a full scan, since none of them can extend past it.
+	scanned := make([]int, len(s.anchored))
+	s.matcher.findAll(text[from:to], func(pattern, end int) {
+		idx := s.owners[pattern]
+		end += from
+		start := end - len(s.anchors[pattern])
+		if start < scanned[idx] {
+			return
+		}
+		d := s.anchored[idx]
+		var winStart, winEnd int
+		if d.scope == scopeToken {
+			winStart = strings.LastIndexAny(text[:start], asciiSpace) + 1
+			winEnd = len(text)
+			if sp := strings.IndexAny(text[end:], asciiSpace); sp >= 0 {
+				winEnd = end + sp
+			}
+		} else {
+			winStart = strings.LastIndexByte(text[:start], '\n') + 1
+			winEnd = len(text)
+			if nl := strings.IndexByte(text[end:], '\n'); nl >= 0 {
+				winEnd = end + nl + 1
+			}
+		}
+		scanned[idx] = winEnd
+		keep(d.match(text[winStart:winEnd]), winStart, d.Context)
+	})
+	return findings
+}
+
+const asciiSpace = " \t\n\v\f\r"
+
+// scopeOf tells whether matches of the pattern never contain whitespace,
+// never contain a newline, or may contain anything. Patterns anchored to the
+// beginning or end of the text or a line are never narrowed.
+func scopeOf(pattern string) matchScope {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return scopeText
+	}
+	scope := scopeToken
+	var walk func(re *syntax.Regexp)
+	walk = func(re *syntax.Regexp) {
+		switch re.Op {
+		case syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText, syntax.OpAnyChar:
+			scope = scopeText
+		case syntax.OpAnyCharNotNL:
+			scope = min(scope, scopeLine)
+		case syntax.OpLiteral:
+			for _, r := range re.Rune {
+				scope = min(scope, runeScope(r))
+			}
+		case syntax.OpCharClass:
+			for i := 0; i+1 < len(re.Rune); i += 2 {
+				for _, r := range asciiSpace {
+					if re.Rune[i] <= r && r <= re.Rune[i+1] {
+						scope = min(scope, runeScope(r))
+					}
+				}
+			}
+		}
+		for _, sub := range re.Sub {
+			walk(sub)
+		}
+	}
+	walk(re)
+	return scope
+}
+
+func runeScope(r rune) matchScope {
+	switch {
+	case r == '\n':
+		return scopeText
+	case strings.ContainsRune(asciiSpace, r):
+		return scopeLine
+	}
+	return scopeToken
+}
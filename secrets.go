@@ -0,0 +1,118 @@
+package anonymizer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Character sets the entropy of a token is measured over.
+const (
+	Base64Charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/=_-"
+	HexCharset    = "0123456789abcdefABCDEF"
+)
+
+var entropyTokenRegex = regexp.MustCompile(`[A-Za-z0-9+/_-]+=*`)
+
+// EntropyDetector reports tokens that look random enough to be secrets of
+// an unknown format: runs of base64 or hex characters of at least MinLength
+// whose Shannon entropy, in bits per character, reaches the threshold of
+// their charset. A token of n characters holds at most log2(n) bits per
+// character, so short tokens are held to that bound less LengthMargin
+// when it is below the threshold.
+type EntropyDetector struct {
+	name            string
+	MinLength       int
+	Base64Threshold float64
+	HexThreshold    float64
+	LengthMargin    float64
+	Context         *Context
+}
+
+func NewEntropyDetector(name string) *EntropyDetector {
+	return &EntropyDetector{
+		name:            name,
+		MinLength:       20,
+		Base64Threshold: 4.5,
+		HexThreshold:    3.0,
+		LengthMargin:    0.5,
+		Context: &Context{
+			Words: []string{"token", "key", "secret", "password", "passwd", "pwd", "auth", "credential", "api", "bearer"},
+			Score: 0.6,
+		},
+	}
+}
+
+func (d *EntropyDetector) Name() string {
+	return d.name
+}
+
+func (d *EntropyDetector) Find(text string) []Finding {
+	var findings []Finding
+	for _, loc := range entropyTokenRegex.FindAllStringIndex(text, -1) {
+		token := text[loc[0]:loc[1]]
+		if len(token) < d.MinLength {
+			continue
+		}
+		threshold := d.Base64Threshold
+		if isCharset(token, HexCharset) {
+			threshold = d.HexThreshold
+		}
+		threshold = min(threshold, math.Log2(float64(len(token)))-d.LengthMargin)
+		if ShannonEntropy(token) < threshold {
+			continue
+		}
+		f := Finding{Detector: d.name, Value: token, Start: loc[0], End: loc[1], Confidence: 1}
+		if d.Context != nil {
+			f = d.Context.Apply(text, f)
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// ShannonEntropy returns the entropy of s in bits per character.
+func ShannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	entropy := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+func isCharset(s, charset string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune(charset, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isJWT reports whether the header of a token decodes to a JSON object
+// naming a signing algorithm.
+func isJWT(token string) bool {
+	header, _, _ := strings.Cut(token, ".")
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(header, "="))
+	if err != nil {
+		return false
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return false
+	}
+	_, ok := fields["alg"]
+	return ok
+}
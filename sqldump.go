@@ -0,0 +1,637 @@
+package anonymizer
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SQLDumpOptions configures AnonymizeSQLDump.
+type SQLDumpOptions struct {
+	// Dialect is "postgres" or "mysql". When empty, strings take backslash
+	// escapes, as in mysqldump output, until the dump turns
+	// standard_conforming_strings on, as pg_dump output does.
+	Dialect string `json:"dialect"`
+}
+
+// AnonymizeSQLDump anonymizes a pg_dump or mysqldump plain text dump
+// statement by statement. Rules pick the columns they apply to through
+// Rule.Field, a column name or a column qualified by its table such as
+// "users.email" or "public.users.email", and apply to the cells of
+// multi-row INSERT statements and of COPY ... FROM stdin blocks. Columns of
+// INSERT statements without a column list are the ones of the CREATE TABLE
+// statement of their table.
+//
+// A value replaced by the same replacer with the same parameter gets the
+// same replacement in every table, so keys stay joinable; the pseudonyms
+// are kept in memory for the whole dump. DDL, comments, NULLs and the
+// untouched cells are written back as read.
+func AnonymizeSQLDump(r io.Reader, w io.Writer, rules []Rule, opts SQLDumpOptions) error {
+	a := &sqlDumpAnonymizer{
+		w:          bufio.NewWriter(w),
+		rules:      rules,
+		lexer:      sqlLexer{backslash: opts.Dialect != "postgres", dollar: opts.Dialect != "mysql"},
+		auto:       opts.Dialect == "",
+		delimiter:  ";",
+		tables:     map[string][]string{},
+		pseudonyms: newPseudonymCache(),
+	}
+	reader := bufio.NewReader(r)
+	var pending strings.Builder
+	var copyColumns [][]Rule
+	inCopy := false
+	for {
+		line, err := reader.ReadString('\n')
+		if line == "" && err == io.EOF {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		switch {
+		case inCopy:
+			if strings.TrimRight(line, "\r\n") == `\.` {
+				inCopy = false
+				a.w.WriteString(line)
+			} else {
+				a.copyRow(line, copyColumns)
+			}
+		case pending.Len() == 0 && a.lexer.idle() && sqlDelimiterRegex.MatchString(line):
+			a.delimiter = sqlDelimiterRegex.FindStringSubmatch(line)[1]
+			a.w.WriteString(line)
+		default:
+			base := pending.Len()
+			pending.WriteString(line)
+			ends := a.lexer.statementEnds(line, a.delimiter)
+			if len(ends) == 0 && err == nil {
+				continue
+			}
+			text := pending.String()
+			last := 0
+			for _, end := range ends {
+				if columns, ok := a.statement(text[last : base+end]); ok {
+					copyColumns, inCopy = columns, true
+				}
+				last = base + end
+			}
+			pending.Reset()
+			if tail := text[last:]; strings.TrimSpace(tail) == "" || err != nil {
+				a.w.WriteString(tail)
+			} else {
+				pending.WriteString(tail)
+			}
+		}
+	}
+	a.w.WriteString(pending.String())
+	return a.w.Flush()
+}
+
+var (
+	sqlDelimiterRegex = regexp.MustCompile(`(?i)^\s*DELIMITER\s+(\S+)`)
+	sqlConformingOn   = regexp.MustCompile(`(?i)^SET\s+standard_conforming_strings\s*(=|TO)\s*'?on\b`)
+)
+
+type sqlDumpAnonymizer struct {
+	w         *bufio.Writer
+	rules     []Rule
+	lexer     sqlLexer
+	auto      bool
+	delimiter string
+	// tables holds the columns of the tables created in the dump.
+	tables     map[string][]string
+	pseudonyms *pseudonymCache
+}
+
+// statement writes a statement, rewritten when it inserts rows. It returns
+// the rules of the columns of a COPY ... FROM stdin statement, whose rows
+// follow.
+func (a *sqlDumpAnonymizer) statement(stmt string) ([][]Rule, bool) {
+	lex := sqlLexer{backslash: a.lexer.backslash, dollar: a.lexer.dollar}
+	tokens := lex.tokens(stmt)
+	if len(tokens) == 0 {
+		a.w.WriteString(stmt)
+		return nil, false
+	}
+	code := stmt[tokens[0].start:]
+	switch keyword := strings.ToUpper(tokens[0].text); {
+	case keyword == "INSERT" || keyword == "REPLACE":
+		a.w.WriteString(a.insert(stmt, tokens))
+		return nil, false
+	case keyword == "CREATE":
+		if table, columns := sqlCreateTable(tokens); table != "" {
+			a.tables[table] = columns
+		}
+	case keyword == "COPY":
+		a.w.WriteString(stmt)
+		table, columns, i := sqlTableColumns(tokens, 1)
+		if i < len(tokens)-1 && strings.EqualFold(tokens[i].text, "FROM") && strings.EqualFold(tokens[i+1].text, "stdin") {
+			return sqlColumnRules(a.rules, table, columns), true
+		}
+		return nil, false
+	case keyword == "SET" && a.auto && sqlConformingOn.MatchString(code):
+		a.lexer.backslash = false
+	}
+	a.w.WriteString(stmt)
+	return nil, false
+}
+
+// insert rewrites the cells of the rows of an INSERT statement.
+func (a *sqlDumpAnonymizer) insert(stmt string, tokens []sqlToken) string {
+	i := 1
+	for i < len(tokens) && !strings.EqualFold(tokens[i].text, "INTO") && tokens[i].kind == sqlWord {
+		i++
+	}
+	table, columns, i := sqlTableColumns(tokens, i+1)
+	if i >= len(tokens) || (!strings.EqualFold(tokens[i].text, "VALUES") && !strings.EqualFold(tokens[i].text, "VALUE")) {
+		return stmt
+	}
+	if columns == nil {
+		if columns = a.tables[table]; columns == nil {
+			columns = a.tables[sqlBareName(table)]
+		}
+	}
+	columnRules := sqlColumnRules(a.rules, table, columns)
+	var b strings.Builder
+	last, depth, column, cellStart := 0, 0, 0, 0
+	for i++; i < len(tokens); i++ {
+		t := tokens[i]
+		if depth == 0 && (t.kind != sqlPunct || (t.text != "(" && t.text != ",")) {
+			// The rows end with the statement or a clause such as ON CONFLICT.
+			break
+		}
+		if t.kind != sqlPunct {
+			continue
+		}
+		switch t.text {
+		case "(":
+			if depth++; depth == 1 {
+				column, cellStart = 0, i+1
+			}
+		case ",", ")":
+			if depth == 1 {
+				if column < len(columnRules) && len(columnRules[column]) > 0 {
+					start, end, value, ok := a.cell(stmt, tokens[cellStart:i], columnRules[column])
+					if ok {
+						b.WriteString(stmt[last:start])
+						b.WriteString(value)
+						last = end
+					}
+				}
+				column, cellStart = column+1, i+1
+			}
+			if t.text == ")" {
+				depth--
+			}
+		}
+	}
+	b.WriteString(stmt[last:])
+	return b.String()
+}
+
+// cell replaces the literal of a cell of an INSERT statement. It returns
+// the span of the literal and its replacement. Only strings, prefixed by E
+// or N or followed by a cast or not, and numbers are replaced.
+func (a *sqlDumpAnonymizer) cell(stmt string, tokens []sqlToken, rules []Rule) (int, int, string, bool) {
+	if len(tokens) == 0 {
+		return 0, 0, "", false
+	}
+	first := tokens[0]
+	prefix := ""
+	if first.kind == sqlWord && len(tokens) > 1 && tokens[1].kind == sqlString && tokens[1].start == first.end {
+		if prefix = strings.ToUpper(first.text); prefix != "E" && prefix != "N" {
+			return 0, 0, "", false
+		}
+		first = tokens[1]
+	}
+	if first.kind == sqlString {
+		backslash := a.lexer.backslash || prefix == "E"
+		value, changed := a.pseudonyms.replace(sqlUnquote(first.text, backslash), rules)
+		if !changed {
+			return 0, 0, "", false
+		}
+		return first.start, first.end, sqlQuote(value, backslash), true
+	}
+	start, end := first.start, tokens[len(tokens)-1].end
+	number := stmt[start:end]
+	if _, err := strconv.ParseFloat(number, 64); err != nil {
+		return 0, 0, "", false
+	}
+	value, changed := a.pseudonyms.replace(number, rules)
+	if !changed {
+		return 0, 0, "", false
+	}
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		value = sqlQuote(value, a.lexer.backslash)
+	}
+	return start, end, value, true
+}
+
+// copyRow writes a row of a COPY block, in the text format of PostgreSQL.
+func (a *sqlDumpAnonymizer) copyRow(line string, columnRules [][]Rule) {
+	row := strings.TrimRight(line, "\r\n")
+	cells := strings.Split(row, "\t")
+	for i, cell := range cells {
+		if i >= len(columnRules) || len(columnRules[i]) == 0 || cell == `\N` {
+			continue
+		}
+		if value, changed := a.pseudonyms.replace(copyUnescape(cell), columnRules[i]); changed {
+			cells[i] = copyEscape(value)
+		}
+	}
+	a.w.WriteString(strings.Join(cells, "\t"))
+	a.w.WriteString(line[len(row):])
+}
+
+// sqlColumnRules returns the rules of each column of a table, named by a
+// column name or a column qualified by its table.
+func sqlColumnRules(rules []Rule, table string, columns []string) [][]Rule {
+	bare := sqlBareName(table)
+	columnRules := make([][]Rule, len(columns))
+	for i, column := range columns {
+		for _, rule := range rules {
+			if strings.EqualFold(rule.Field, column) || strings.EqualFold(rule.Field, bare+"."+column) || strings.EqualFold(rule.Field, table+"."+column) {
+				columnRules[i] = append(columnRules[i], rule)
+			}
+		}
+	}
+	return columnRules
+}
+
+// pseudonymCache remembers the replacements of values by replacer and
+// parameter, so a value gets the same replacement wherever it is found.
+type pseudonymCache struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func newPseudonymCache() *pseudonymCache {
+	return &pseudonymCache{values: map[string]string{}}
+}
+
+// replace applies the rules of a column to a value, the last applying rule
+// winning.
+func (c *pseudonymCache) replace(value string, rules []Rule) (string, bool) {
+	var replaced *string
+	for _, rule := range rules {
+		if !rule.applies(rule.Field, value) {
+			continue
+		}
+		ruler, ok := lookupReplacer(rule.Type)
+		if !ok {
+			continue
+		}
+		key := rule.Type + "\x00" + rule.Value + "\x00" + value
+		c.mu.Lock()
+		v, ok := c.values[key]
+		c.mu.Unlock()
+		if !ok {
+			v = replaceString(ruler, value, rule.Value)
+			c.mu.Lock()
+			if cached, found := c.values[key]; found {
+				v = cached
+			} else {
+				c.values[key] = v
+			}
+			c.mu.Unlock()
+		}
+		replaced = &v
+	}
+	if replaced == nil {
+		return value, false
+	}
+	return *replaced, *replaced != value
+}
+
+// sqlCreateTable returns the table and the columns of a CREATE TABLE
+// statement.
+func sqlCreateTable(tokens []sqlToken) (string, []string) {
+	i := 1
+	for i < len(tokens) && tokens[i].kind == sqlWord && !strings.EqualFold(tokens[i].text, "TABLE") {
+		i++
+	}
+	if i >= len(tokens) || !strings.EqualFold(tokens[i].text, "TABLE") {
+		return "", nil
+	}
+	i++
+	for i+1 < len(tokens) && (strings.EqualFold(tokens[i].text, "IF") || strings.EqualFold(tokens[i].text, "NOT") || strings.EqualFold(tokens[i].text, "EXISTS")) {
+		i++
+	}
+	table, i := sqlTableName(tokens, i)
+	if i >= len(tokens) || tokens[i].text != "(" {
+		return "", nil
+	}
+	var columns []string
+	depth, element := 0, true
+	for ; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.kind == sqlPunct && t.text == "(":
+			depth++
+		case t.kind == sqlPunct && t.text == ")":
+			if depth--; depth == 0 {
+				return table, columns
+			}
+		case t.kind == sqlPunct && t.text == "," && depth == 1:
+			element = true
+		case element && depth == 1:
+			element = false
+			if t.kind == sqlWord && sqlTableConstraints[strings.ToUpper(t.text)] {
+				continue
+			}
+			columns = append(columns, sqlIdentifier(t.text))
+		}
+	}
+	return table, columns
+}
+
+var sqlTableConstraints = map[string]bool{
+	"CONSTRAINT": true, "PRIMARY": true, "UNIQUE": true, "KEY": true, "INDEX": true, "FOREIGN": true,
+	"CHECK": true, "FULLTEXT": true, "SPATIAL": true, "EXCLUDE": true, "LIKE": true, "PERIOD": true,
+}
+
+// sqlTableColumns returns the table and the optional column list starting
+// at tokens[i], and the index of the token following them.
+func sqlTableColumns(tokens []sqlToken, i int) (string, []string, int) {
+	table, i := sqlTableName(tokens, i)
+	if i >= len(tokens) || tokens[i].text != "(" {
+		return table, nil, i
+	}
+	columns := []string{}
+	for i++; i < len(tokens) && tokens[i].text != ")"; i++ {
+		if tokens[i].kind != sqlPunct {
+			columns = append(columns, sqlIdentifier(tokens[i].text))
+		}
+	}
+	return table, columns, i + 1
+}
+
+// sqlTableName returns the name starting at tokens[i], with its schema,
+// unquoted and lower-cased.
+func sqlTableName(tokens []sqlToken, i int) (string, int) {
+	var parts []string
+	for ; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind == sqlPunct && t.text == "." {
+			continue
+		}
+		if t.kind == sqlPunct || (len(parts) > 0 && tokens[i-1].text != ".") {
+			break
+		}
+		for _, part := range strings.Split(t.text, ".") {
+			parts = append(parts, strings.ToLower(sqlIdentifier(part)))
+		}
+	}
+	return strings.Join(parts, "."), i
+}
+
+func sqlBareName(table string) string {
+	return table[strings.LastIndex(table, ".")+1:]
+}
+
+// sqlIdentifier unquotes an identifier.
+func sqlIdentifier(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '`') && s[len(s)-1] == s[0] {
+		q := s[:1]
+		return strings.ReplaceAll(s[1:len(s)-1], q+q, q)
+	}
+	return s
+}
+
+// sqlUnquote decodes a string literal, which takes backslash escapes as in
+// MySQL or in the E strings of PostgreSQL when backslash is set.
+func sqlUnquote(s string, backslash bool) string {
+	s = s[1 : len(s)-1]
+	if !backslash {
+		return strings.ReplaceAll(s, "''", "'")
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch c = s[i]; c {
+			case '0':
+				c = 0
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'Z':
+				c = 0x1a
+			case '%', '_':
+				b.WriteByte('\\')
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// sqlQuote encodes a string literal as sqlUnquote decodes it.
+func sqlQuote(s string, backslash bool) string {
+	if !backslash {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+	return "'" + sqlEscaper.Replace(s) + "'"
+}
+
+var sqlEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\x00", `\0`, "\n", `\n`, "\r", `\r`, "\x1a", `\Z`)
+
+// copyUnescape decodes a cell of the text format of COPY.
+func copyUnescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; {
+		case c >= '0' && c <= '7':
+			n, j := 0, i
+			for ; j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7'; j++ {
+				n = n*8 + int(s[j]-'0')
+			}
+			b.WriteByte(byte(n))
+			i = j - 1
+		case c == 'x' && i+1 < len(s) && isHexDigit(s[i+1]):
+			n, j := 0, i+1
+			for ; j < len(s) && j < i+3 && isHexDigit(s[j]); j++ {
+				d, _ := strconv.ParseUint(s[j:j+1], 16, 8)
+				n = n*16 + int(d)
+			}
+			b.WriteByte(byte(n))
+			i = j - 1
+		default:
+			b.WriteByte(copyEscapes[c])
+		}
+	}
+	return b.String()
+}
+
+var copyEscapes = func() (m [256]byte) {
+	for i := range m {
+		m[i] = byte(i)
+	}
+	m['b'], m['f'], m['n'], m['r'], m['t'], m['v'] = '\b', '\f', '\n', '\r', '\t', '\v'
+	return m
+}()
+
+var copyEscaper = strings.NewReplacer(`\`, `\\`, "\b", `\b`, "\f", `\f`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "\v", `\v`)
+
+// copyEscape encodes a cell as copyUnescape decodes it.
+func copyEscape(s string) string {
+	return copyEscaper.Replace(s)
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// Kinds of SQL tokens.
+const (
+	sqlWord = iota
+	sqlString
+	sqlQuoted
+	sqlPunct
+)
+
+type sqlToken struct {
+	kind       int
+	text       string
+	start, end int
+}
+
+// sqlLexer scans SQL text, possibly a line at a time: its state is the
+// string, quoted identifier or comment left open at the end of the text.
+type sqlLexer struct {
+	// backslash tells strings take backslash escapes, as in MySQL;
+	// dollar that PostgreSQL dollar-quoted strings are recognized.
+	backslash, dollar bool
+
+	quote   byte
+	escapes bool
+	tag     string
+	comment bool
+}
+
+var sqlDollarTagRegex = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+func (l *sqlLexer) idle() bool {
+	return l.quote == 0 && l.tag == "" && !l.comment
+}
+
+// next returns the end of the token starting at s[i], and whether it is
+// code rather than a string, a quoted identifier or a comment. Strings,
+// quoted identifiers and comments left open end at the end of s.
+func (l *sqlLexer) next(s string, i int) (int, bool) {
+	if l.idle() {
+		switch c := s[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			l.quote = c
+			l.escapes = l.backslash && c != '`' || (c == '\'' && i > 0 && (s[i-1] == 'E' || s[i-1] == 'e') && (i == 1 || !isIdentChar(s[i-2])))
+			i++
+		case c == '-' && strings.HasPrefix(s[i:], "--"):
+			if end := strings.IndexByte(s[i:], '\n'); end >= 0 {
+				return i + end, false
+			}
+			return len(s), false
+		case c == '/' && strings.HasPrefix(s[i:], "/*"):
+			l.comment = true
+			i += 2
+		case c == '$' && l.dollar && (i == 0 || !isIdentChar(s[i-1])) && sqlDollarTagRegex.MatchString(s[i:]):
+			l.tag = sqlDollarTagRegex.FindString(s[i:])
+			i += len(l.tag)
+		default:
+			return i + 1, true
+		}
+	}
+	switch {
+	case l.comment:
+		if end := strings.Index(s[i:], "*/"); end >= 0 {
+			l.comment = false
+			return i + end + 2, false
+		}
+	case l.tag != "":
+		if end := strings.Index(s[i:], l.tag); end >= 0 {
+			end = i + end + len(l.tag)
+			l.tag = ""
+			return end, false
+		}
+	default:
+		for ; i < len(s); i++ {
+			switch {
+			case s[i] == '\\' && l.escapes && l.quote != '`':
+				i++
+			case s[i] == l.quote:
+				if i+1 < len(s) && s[i+1] == l.quote {
+					i++
+					continue
+				}
+				l.quote = 0
+				return i + 1, false
+			}
+		}
+	}
+	return len(s), false
+}
+
+// statementEnds returns the offsets in line just after the delimiters
+// ending statements.
+func (l *sqlLexer) statementEnds(line, delimiter string) []int {
+	var ends []int
+	for i := 0; i < len(line); {
+		j, code := l.next(line, i)
+		if code && strings.HasPrefix(line[i:], delimiter) {
+			j = i + len(delimiter)
+			ends = append(ends, j)
+		}
+		i = j
+	}
+	return ends
+}
+
+// tokens splits a statement into words, strings, quoted identifiers and
+// punctuation, skipping spaces and comments. Words hold the dots of
+// numbers and qualified names.
+func (l *sqlLexer) tokens(s string) []sqlToken {
+	var tokens []sqlToken
+	for i := 0; i < len(s); {
+		j, code := l.next(s, i)
+		switch {
+		case !code && (s[i] == '\'' || s[i] == '"' && l.backslash):
+			tokens = append(tokens, sqlToken{kind: sqlString, text: s[i:j], start: i, end: j})
+		case !code && (s[i] == '"' || s[i] == '`' || s[i] == '$'):
+			// Dollar-quoted strings are kept as they are, like quoted identifiers.
+			tokens = append(tokens, sqlToken{kind: sqlQuoted, text: s[i:j], start: i, end: j})
+		case code && isIdentChar(s[i]):
+			for j < len(s) && (isIdentChar(s[j]) || s[j] == '.' && j+1 < len(s) && isIdentChar(s[j+1])) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: sqlWord, text: s[i:j], start: i, end: j})
+		case code && !isSpace(rune(s[i])):
+			tokens = append(tokens, sqlToken{kind: sqlPunct, text: s[i:j], start: i, end: j})
+		}
+		i = j
+	}
+	return tokens
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,103 @@
+package anonymizer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func anonymizeSQLDump(t *testing.T, dump string, rules []Rule, opts SQLDumpOptions) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := AnonymizeSQLDump(strings.NewReader(dump), &buf, rules, opts); err != nil {
+		t.Fatalf("AnonymizeSQLDump: %v", err)
+	}
+	return buf.String()
+}
+
+func TestAnonymizeSQLDumpMySQL(t *testing.T) {
+	ana := replaceString(&Hasher{}, "ana@example.com", "")
+	dump := "-- MySQL dump; with a semicolon\n" +
+		"CREATE TABLE `users` (\n  `id` int NOT NULL,\n  `email` varchar(255),\n  `name` text,\n  PRIMARY KEY (`id`)\n);\n" +
+		"INSERT INTO `users` VALUES (1,'ana@example.com','O\\'Brien; Jr'),(2,NULL,'Bob'),\n(3,'ana@example.com',\"Carl\");\n" +
+		"DELIMITER ;;\nCREATE TRIGGER t BEFORE INSERT ON users FOR EACH ROW BEGIN SET NEW.name = 'x'; END ;;\nDELIMITER ;\n" +
+		"INSERT INTO orders (id, total, email) VALUES (7, -12.5, 'ana@example.com') ON DUPLICATE KEY UPDATE email='keep@example.com';\n"
+	want := "-- MySQL dump; with a semicolon\n" +
+		"CREATE TABLE `users` (\n  `id` int NOT NULL,\n  `email` varchar(255),\n  `name` text,\n  PRIMARY KEY (`id`)\n);\n" +
+		"INSERT INTO `users` VALUES (1,'" + ana + "','***********'),(2,NULL,'***'),\n(3,'" + ana + "','****');\n" +
+		"DELIMITER ;;\nCREATE TRIGGER t BEFORE INSERT ON users FOR EACH ROW BEGIN SET NEW.name = 'x'; END ;;\nDELIMITER ;\n" +
+		"INSERT INTO orders (id, total, email) VALUES (7, '*****', '" + ana + "') ON DUPLICATE KEY UPDATE email='keep@example.com';\n"
+	rules := []Rule{{Field: "email", Type: "hash"}, {Field: "users.name", Type: "asterisk"}, {Field: "orders.total", Type: "asterisk"}}
+	if got := anonymizeSQLDump(t, dump, rules, SQLDumpOptions{Dialect: "mysql"}); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAnonymizeSQLDumpPostgres(t *testing.T) {
+	dump := "SET standard_conforming_strings = on;\n" +
+		"COPY public.users (id, email, note) FROM stdin;\n" +
+		"1\tana@example.com\tline\\none\n" +
+		"2\t\\N\ttab\\there\n" +
+		"\\.\n" +
+		"INSERT INTO public.users (id, email, note) VALUES (3, 'it''s@x.io', E'a\\\\b'), (4, 'c:\\d@x.io', $$x$$);\n" +
+		"CREATE FUNCTION f() RETURNS text AS $body$ SELECT 'a;b'; $body$ LANGUAGE sql;\n"
+	want := "SET standard_conforming_strings = on;\n" +
+		"COPY public.users (id, email, note) FROM stdin;\n" +
+		"1\t***************\t********\n" +
+		"2\t\\N\t********\n" +
+		"\\.\n" +
+		"INSERT INTO public.users (id, email, note) VALUES (3, '*********', E'***'), (4, '*********', $$x$$);\n" +
+		"CREATE FUNCTION f() RETURNS text AS $body$ SELECT 'a;b'; $body$ LANGUAGE sql;\n"
+	rules := []Rule{{Field: "public.users.email", Type: "asterisk"}, {Field: "note", Type: "asterisk"}}
+	for _, dialect := range []string{"", "postgres"} {
+		if got := anonymizeSQLDump(t, dump, rules, SQLDumpOptions{Dialect: dialect}); got != want {
+			t.Errorf("dialect %q: got\n%s\nwant\n%s", dialect, got, want)
+		}
+	}
+}
+
+func TestAnonymizeSQLDumpPseudonyms(t *testing.T) {
+	if err := AddCustomReplacer("dump_sequence", &sequenceReplacer{}); err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveCustomReplacer("dump_sequence")
+	dump := "COPY customers (id, email) FROM stdin;\n1\tana@example.com\n2\tbob@example.com\n\\.\n" +
+		"INSERT INTO orders (id, customer) VALUES (1, 'bob@example.com'), (2, 'ana@example.com');\n"
+	want := "COPY customers (id, email) FROM stdin;\n1\tcustomer-1\n2\tcustomer-2\n\\.\n" +
+		"INSERT INTO orders (id, customer) VALUES (1, 'customer-2'), (2, 'customer-1');\n"
+	rules := []Rule{{Field: "email", Type: "dump_sequence"}, {Field: "orders.customer", Type: "dump_sequence"}}
+	if got := anonymizeSQLDump(t, dump, rules, SQLDumpOptions{Dialect: "postgres"}); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAnonymizeSQLDumpUntouched(t *testing.T) {
+	dump := "/* header; */\nCREATE TABLE t (a text);\nINSERT INTO t VALUES ('x')\n"
+	if got := anonymizeSQLDump(t, dump, []Rule{{Field: "b", Type: "asterisk"}}, SQLDumpOptions{}); got != dump {
+		t.Errorf("got %q, want the dump as read", got)
+	}
+}
+
+func TestSQLQuote(t *testing.T) {
+	for _, s := range []string{"plain", "it's", `back\slash`, "two\nlines\r\x00\x1a"} {
+		for _, backslash := range []bool{false, true} {
+			if got := sqlUnquote(sqlQuote(s, backslash), backslash); got != s {
+				t.Errorf("sqlUnquote(sqlQuote(%q, %v)) = %q", s, backslash, got)
+			}
+		}
+	}
+	if got := sqlUnquote(`'a\%b\_c'`, true); got != `a\%b\_c` {
+		t.Errorf("sqlUnquote kept %q", got)
+	}
+}
+
+func TestCopyEscape(t *testing.T) {
+	for _, s := range []string{"plain", "tab\there", "new\nline", `back\slash`} {
+		if got := copyUnescape(copyEscape(s)); got != s {
+			t.Errorf("copyUnescape(copyEscape(%q)) = %q", s, got)
+		}
+	}
+	if got := copyUnescape(`\101\x42\q`); got != "ABq" {
+		t.Errorf("copyUnescape = %q", got)
+	}
+}
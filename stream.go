@@ -0,0 +1,219 @@
+package anonymizer
+
+import (
+	"io"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	DefaultStreamChunkSize = 64 << 10
+	// DefaultStreamOverlap is the overlap kept for detectors whose matches
+	// have no upper bound.
+	DefaultStreamOverlap = 4096
+	// DefaultStreamMaxPending is how much input is held back after the
+	// BEGIN line of a PEM block waiting for its END line.
+	DefaultStreamMaxPending = 1 << 20
+)
+
+// RedactingWriter redacts the text written to it according to a policy
+// before passing it on. The tail of the input, as long as the longest match
+// of any detector and the context words around it, is held back until more
+// input or Close arrives, so matches spanning two writes are redacted whole
+// and scored with their context. A PEM block is held back from its BEGIN
+// line until its END line arrives, up to DefaultStreamMaxPending bytes.
+type RedactingWriter struct {
+	w       io.Writer
+	policy  Policy
+	overlap int
+	context int
+	pem     bool
+	// buf holds the input not written yet, after the head bytes already
+	// written and kept as the context of what follows.
+	buf  []byte
+	head int
+	next int
+	err  error
+}
+
+func NewRedactingWriter(w io.Writer, policy Policy) *RedactingWriter {
+	rw := &RedactingWriter{w: w, policy: policy}
+	window := DefaultContextWindow
+	for _, d := range policy.detectors() {
+		n := -1
+		if bounded, ok := d.(interface{ MaxLen() int }); ok {
+			n = bounded.MaxLen()
+		}
+		if n < 0 || n > DefaultStreamOverlap {
+			n = DefaultStreamOverlap
+		}
+		rw.overlap = max(rw.overlap, n)
+		if rd, ok := d.(*RegexDetector); ok {
+			if rd.Context != nil {
+				window = max(window, rd.Context.Window)
+			}
+			for _, anchor := range rd.Anchors {
+				rw.pem = rw.pem || anchor == pemBegin
+			}
+		}
+	}
+	// Context words are looked for up to window words on either side.
+	rw.context = window * (maxContextWordLen + 1)
+	rw.overlap += rw.context
+	rw.next = DefaultStreamChunkSize + rw.overlap
+	return rw
+}
+
+func (rw *RedactingWriter) Write(p []byte) (int, error) {
+	if rw.err != nil {
+		return 0, rw.err
+	}
+	rw.buf = append(rw.buf, p...)
+	if len(rw.buf)-rw.head >= rw.next {
+		if err := rw.flush(false); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close redacts and writes the buffered input. It does not close the
+// underlying writer.
+func (rw *RedactingWriter) Close() error {
+	if rw.err != nil {
+		return rw.err
+	}
+	return rw.flush(true)
+}
+
+const pemBegin = "-----BEGIN"
+
+// flush writes out the part of the buffer no later input can change.
+func (rw *RedactingWriter) flush(final bool) error {
+	text := string(rw.buf)
+	cut := len(text)
+	if !final {
+		cut -= rw.overlap
+		if rw.pem {
+			if begin := unterminatedPEM(text[rw.head:]); begin >= 0 && len(text)-rw.head-begin < DefaultStreamMaxPending {
+				cut = min(cut, rw.head+begin)
+			}
+		}
+		for cut > rw.head && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		if cut <= rw.head {
+			// Wait for another chunk rather than scan the same text again.
+			rw.next = len(text) - rw.head + DefaultStreamChunkSize
+			return nil
+		}
+	}
+	var findings []Finding
+	for _, f := range rw.policy.Find(text) {
+		// Matches in the head were redacted by the previous flush.
+		if f.Start < rw.head {
+			continue
+		}
+		if f.Start >= cut {
+			break
+		}
+		f.Start, f.End = f.Start-rw.head, f.End-rw.head
+		findings = append(findings, f)
+		cut = max(cut, rw.head+f.End)
+	}
+	if _, err := io.WriteString(rw.w, rw.policy.redact(text[rw.head:cut], findings)); err != nil {
+		rw.err = err
+		return err
+	}
+	head := max(cut-rw.context, 0)
+	for head > 0 && !utf8.RuneStart(text[head]) {
+		head--
+	}
+	rw.buf = append(rw.buf[:0], text[head:]...)
+	rw.head = cut - head
+	rw.next = DefaultStreamChunkSize + rw.overlap
+	return nil
+}
+
+// unterminatedPEM returns the offset of the BEGIN line of a PEM block with
+// no END line yet in text, or -1.
+func unterminatedPEM(text string) int {
+	begin := strings.LastIndex(text, pemBegin)
+	if begin < 0 || strings.Contains(text[begin:], "-----END") {
+		return -1
+	}
+	return begin
+}
+
+// RedactStream copies r to w, redacting it according to the policy.
+func RedactStream(r io.Reader, w io.Writer, policy Policy) error {
+	rw := NewRedactingWriter(w, policy)
+	if _, err := io.Copy(rw, r); err != nil {
+		return err
+	}
+	return rw.Close()
+}
+
+// maxLenOf returns the longest match in bytes of a pattern, or -1 when the
+// pattern is unbounded or cannot be parsed.
+func maxLenOf(pattern string) int {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return -1
+	}
+	return maxLenOfSyntax(re)
+}
+
+func maxLenOfSyntax(re *syntax.Regexp) int {
+	switch re.Op {
+	case syntax.OpLiteral:
+		n := 0
+		for _, r := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 {
+				n += utf8.UTFMax
+			} else {
+				n += utf8.RuneLen(r)
+			}
+		}
+		return n
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return 0
+		}
+		return utf8.RuneLen(min(re.Rune[len(re.Rune)-1], utf8.MaxRune))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return utf8.UTFMax
+	case syntax.OpCapture, syntax.OpQuest:
+		return maxLenOfSyntax(re.Sub[0])
+	case syntax.OpStar, syntax.OpPlus:
+		return -1
+	case syntax.OpRepeat:
+		n := maxLenOfSyntax(re.Sub[0])
+		if re.Max < 0 || n < 0 {
+			return -1
+		}
+		return re.Max * n
+	case syntax.OpConcat:
+		total := 0
+		for _, sub := range re.Sub {
+			n := maxLenOfSyntax(sub)
+			if n < 0 {
+				return -1
+			}
+			total += n
+		}
+		return total
+	case syntax.OpAlternate:
+		longest := 0
+		for _, sub := range re.Sub {
+			n := maxLenOfSyntax(sub)
+			if n < 0 {
+				return -1
+			}
+			longest = max(longest, n)
+		}
+		return longest
+	}
+	return 0
+}
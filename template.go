@@ -0,0 +1,331 @@
+package anonymizer
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Template is a compiled input pattern: a regular expression in which
+// placeholders such as <name> or <name:type> capture named values. The
+// values of placeholders named "skip" are not returned.
+//
+// Values of typed placeholders such as <id:int> or <when:date:02/01/2006>
+// are converted as described by PlaceholderTypes.
+type Template struct {
+	pattern      string
+	regex        *regexp.Regexp
+	names        []string
+	placeholders map[string]placeholder
+}
+
+// CompileTemplate compiles an input pattern.
+func CompileTemplate(pattern string) (*Template, error) {
+	t := &Template{pattern: pattern, placeholders: make(map[string]placeholder)}
+	var errs []error
+	expr := PlaceHolderRegex.ReplaceAllStringFunc(pattern, func(s string) string {
+		p, err := parsePlaceholder(s)
+		if err != nil {
+			errs = append(errs, err)
+			return s
+		}
+		t.placeholders[p.name] = p
+		return p.group()
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("template %q: %w", pattern, err)
+	}
+	regex, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("template %q: %w", pattern, err)
+	}
+	t.regex = regex
+	for _, name := range regex.SubexpNames() {
+		if name != "" && name != "skip" && !containsString(t.names, name) {
+			t.names = append(t.names, name)
+		}
+	}
+	return t, nil
+}
+
+// String returns the pattern the template was compiled from.
+func (t *Template) String() string {
+	return t.pattern
+}
+
+// Regexp returns the regular expression the template was compiled to.
+func (t *Template) Regexp() *regexp.Regexp {
+	return t.regex
+}
+
+// Names returns the names of the placeholders of the template.
+func (t *Template) Names() []string {
+	return t.names
+}
+
+// Parse returns the values of the placeholders in the first match of the
+// template in data. On mismatch, the values are empty.
+func (t *Template) Parse(data string) (map[string]any, error) {
+	_, values, err := t.ParseWithMatched(data)
+	return values, err
+}
+
+// ParseWithMatched is like Parse and also returns the matched text.
+func (t *Template) ParseWithMatched(data string) (string, map[string]any, error) {
+	matches := t.regex.FindStringSubmatch(data)
+	if matches == nil {
+		values, _ := t.values(nil)
+		return "", values, fmt.Errorf("data did not match input pattern")
+	}
+	values, err := t.values(matches)
+	return matches[0], values, err
+}
+
+// ParseAll returns the values of the placeholders in every match of the
+// template in data.
+func (t *Template) ParseAll(data string) ([]map[string]any, error) {
+	all := t.regex.FindAllStringSubmatch(data, -1)
+	if all == nil {
+		return nil, fmt.Errorf("data did not match input pattern")
+	}
+	values := make([]map[string]any, len(all))
+	for i, matches := range all {
+		var err error
+		if values[i], err = t.values(matches); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// Replace rewrites the first match of the template in data, putting the
+// given values in place of the text captured by their placeholders. The
+// text around the placeholders and the placeholders without a value are
+// kept.
+func (t *Template) Replace(data string, values map[string]any) (string, error) {
+	loc := t.regex.FindStringSubmatchIndex(data)
+	if loc == nil {
+		return data, fmt.Errorf("data did not match input pattern")
+	}
+	return data[:loc[0]] + t.rewrite(data, loc, values) + data[loc[1]:], nil
+}
+
+// rewrite returns the match at loc with the captures of the placeholders
+// replaced by values.
+func (t *Template) rewrite(data string, loc []int, values map[string]any) string {
+	var b strings.Builder
+	last := loc[0]
+	for i, name := range t.regex.SubexpNames() {
+		start, end := loc[2*i], loc[2*i+1]
+		value, ok := values[name]
+		if i == 0 || !ok || start < last {
+			continue
+		}
+		b.WriteString(data[last:start])
+		b.WriteString(t.format(name, value))
+		last = end
+	}
+	b.WriteString(data[last:loc[1]])
+	return b.String()
+}
+
+// values returns the values of the placeholders in matches, converted
+// according to their type, or empty values when matches is nil.
+func (t *Template) values(matches []string) (map[string]any, error) {
+	values := make(map[string]any, len(t.names))
+	for _, name := range t.names {
+		values[name] = ""
+		if matches == nil {
+			continue
+		}
+		value := matches[t.regex.SubexpIndex(name)]
+		values[name] = value
+		if p := t.placeholders[name]; p.convert != nil && value != "" {
+			converted, err := p.convert(value, p.arg)
+			if err != nil {
+				return values, fmt.Errorf("placeholder %q: %w", name, err)
+			}
+			values[name] = converted
+		}
+	}
+	return values, nil
+}
+
+// format writes a value back in the form of its placeholder, e.g. a time
+// in the layout of a date placeholder.
+func (t *Template) format(name string, value any) string {
+	if tm, ok := value.(time.Time); ok && t.placeholders[name].layout {
+		return tm.Format(t.placeholders[name].arg)
+	}
+	return fmt.Sprint(value)
+}
+
+// TemplateCacheSize is the number of compiled templates the string based
+// functions such as Parse keep.
+var TemplateCacheSize = 256
+
+var templates = &templateCache{entries: make(map[string]*list.Element), order: list.New()}
+
+// templateCache keeps the most recently used compiled templates.
+type templateCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// cachedTemplate compiles a pattern, or returns it from the cache.
+func cachedTemplate(pattern string) (*Template, error) {
+	return templates.get(pattern)
+}
+
+func (c *templateCache) get(pattern string) (*Template, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[pattern]; ok {
+		c.order.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*Template), nil
+	}
+	c.mu.Unlock()
+	t, err := CompileTemplate(pattern)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[pattern]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*Template), nil
+	}
+	c.entries[pattern] = c.order.PushFront(t)
+	for c.order.Len() > max(TemplateCacheSize, 1) {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*Template).pattern)
+	}
+	return t, nil
+}
+
+// ReplaceFunc rewrites the first match of the template in data, putting
+// fn(name, captured text) in place of the text captured by each placeholder.
+func (t *Template) ReplaceFunc(data string, fn func(name, value string) string) (string, error) {
+	loc := t.regex.FindStringSubmatchIndex(data)
+	if loc == nil {
+		return data, fmt.Errorf("data did not match input pattern")
+	}
+	return data[:loc[0]] + t.rewrite(data, loc, t.replaced(data, loc, fn)) + data[loc[1]:], nil
+}
+
+// ReplaceAllFunc rewrites every match of the template in data as
+// ReplaceFunc rewrites the first one, keeping the text between matches.
+func (t *Template) ReplaceAllFunc(data string, fn func(name, value string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range t.regex.FindAllStringSubmatchIndex(data, -1) {
+		b.WriteString(data[last:loc[0]])
+		b.WriteString(t.rewrite(data, loc, t.replaced(data, loc, fn)))
+		last = loc[1]
+	}
+	b.WriteString(data[last:])
+	return b.String()
+}
+
+// replaced returns the values fn gives to the placeholders captured at loc.
+func (t *Template) replaced(data string, loc []int, fn func(name, value string) string) map[string]any {
+	values := make(map[string]any, len(t.names))
+	for _, name := range t.names {
+		i := t.regex.SubexpIndex(name)
+		if loc[2*i] >= 0 {
+			values[name] = fn(name, data[loc[2*i]:loc[2*i+1]])
+		}
+	}
+	return values
+}
+
+// TemplateAnonymizer anonymizes the parts of structured strings, such as
+// file names or urls, captured by the placeholders of a template, and keeps
+// the rest of the string. Rules pick the replacer of a placeholder through
+// Rule.Field; placeholders without a rule are kept.
+//
+// Its parameter, when given, holds the template followed by the rules as
+// ";name=replacer[:param]" entries, e.g.
+//
+//	(?i)<facility>-<enc_type>_<fin>\.PDF;fin=hash;enc_type=asterisk
+//
+// A TemplateAnonymizer with a Template is registered with AddCustomReplacer.
+type TemplateAnonymizer struct {
+	Template *Template `json:"-"`
+	Rules    []Rule    `json:"rules"`
+}
+
+func (a *TemplateAnonymizer) Replace(source any, name string) any {
+	switch field := source.(type) {
+	case reflect.Value:
+		field = indirect(field)
+		if field.Kind() != reflect.String {
+			return replacedValue(field)
+		}
+		// Values the template does not apply to are kept.
+		value := field.String()
+		t, rules := a.Template, a.Rules
+		if name != "" {
+			pattern, inline := parseTemplateParam(name)
+			var err error
+			if t, err = cachedTemplate(pattern); err != nil {
+				return value
+			}
+			rules = append(inline, rules...)
+		}
+		if t == nil {
+			return value
+		}
+		replaced, err := t.ReplaceFunc(value, applyRules(rules))
+		if err != nil {
+			return value
+		}
+		return replaced
+	default:
+		return source
+	}
+}
+
+// applyRules returns a function anonymizing the value of a placeholder with
+// the first of rules applying to it.
+func applyRules(rules []Rule) func(name, value string) string {
+	return func(name, value string) string {
+		for _, rule := range rules {
+			if !rule.applies(name, value) {
+				continue
+			}
+			if ruler, ok := lookupReplacer(rule.Type); ok {
+				return replaceString(ruler, value, rule.Value)
+			}
+		}
+		return value
+	}
+}
+
+var templateRuleRegex = regexp.MustCompile(`^\s*(\w+)\s*=\s*(\w+)(?::(.*))?$`)
+
+// parseTemplateParam splits the parameter of a TemplateAnonymizer into the
+// template and the trailing ";name=replacer[:param]" rules.
+func parseTemplateParam(param string) (string, []Rule) {
+	var rules []Rule
+	for {
+		i := strings.LastIndexByte(param, ';')
+		if i < 0 {
+			break
+		}
+		m := templateRuleRegex.FindStringSubmatch(param[i+1:])
+		if m == nil {
+			break
+		}
+		rules = append([]Rule{{Field: m[1], Type: m[2], Value: m[3]}}, rules...)
+		param = param[:i]
+	}
+	return param, rules
+}
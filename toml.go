@@ -0,0 +1,130 @@
+package anonymizer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// AnonymizeTOML anonymizes a TOML document. Rules apply to the strings of
+// the keys named by Rule.Field, or of the dotted paths such as
+// "dependencies.serde.git" the keys make with their table; the strings
+// without a rule are redacted by the options' policy. Strings are replaced
+// in place, so everything else, comments and formatting included, is
+// written back as read.
+func AnonymizeTOML(r io.Reader, w io.Writer, rules []Rule, opts ConfigOptions) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	a := &tomlAnonymizer{rules: rules, policy: opts.policy()}
+	p := &unstable.Parser{}
+	p.Reset(data)
+	var table []string
+	for p.NextExpression() {
+		expr := p.Expression()
+		switch expr.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			table = tomlKey(expr.Key())
+		case unstable.KeyValue:
+			a.value(p, expr.Value(), append(append([]string(nil), table...), tomlKey(expr.Key())...))
+		}
+	}
+	if err := p.Error(); err != nil {
+		if perr, ok := err.(*unstable.ParserError); ok {
+			shape := p.Shape(p.Range(perr.Highlight))
+			return fmt.Errorf("toml line %d: %w", shape.Start.Line, err)
+		}
+		return err
+	}
+	return writeEdits(w, data, a.edits)
+}
+
+type tomlAnonymizer struct {
+	rules  []Rule
+	policy *Policy
+	edits  []configEdit
+}
+
+// value anonymizes the strings of node, the value of the dotted key keys.
+func (a *tomlAnonymizer) value(p *unstable.Parser, node *unstable.Node, keys []string) {
+	switch node.Kind {
+	case unstable.String:
+		key, path := keys[len(keys)-1], strings.Join(keys, ".")
+		value, changed := anonymizeConfigValue(string(node.Data), key, path, a.rules, a.policy)
+		if changed {
+			raw := string(p.Raw(node.Raw))
+			start := int(node.Raw.Offset)
+			a.edits = append(a.edits, configEdit{start: start, end: start + len(raw), text: tomlString(value, raw)})
+		}
+	case unstable.Array:
+		for it := node.Children(); it.Next(); {
+			a.value(p, it.Node(), keys)
+		}
+	case unstable.InlineTable:
+		for it := node.Children(); it.Next(); {
+			kv := it.Node()
+			a.value(p, kv.Value(), append(append([]string(nil), keys...), tomlKey(kv.Key())...))
+		}
+	}
+}
+
+func tomlKey(it unstable.Iterator) []string {
+	var keys []string
+	for it.Next() {
+		keys = append(keys, string(it.Node().Data))
+	}
+	return keys
+}
+
+// tomlString encodes value as a string written like raw: a literal string
+// when raw is one and value can be, else a basic string.
+func tomlString(value, raw string) string {
+	multiline := strings.HasPrefix(raw, `"""`) || strings.HasPrefix(raw, `'''`)
+	if strings.HasPrefix(raw, "'") && !strings.ContainsAny(value, "'\r") && !strings.ContainsFunc(value, isTOMLControl) {
+		if !multiline && !strings.Contains(value, "\n") {
+			return "'" + value + "'"
+		}
+		if multiline && !strings.HasPrefix(value, "\n") {
+			return "'''" + value + "'''"
+		}
+	}
+	var b strings.Builder
+	for i, c := range value {
+		switch c {
+		case '"', '\\':
+			b.WriteString(`\` + string(c))
+		case '\n':
+			// A line break right after the opening delimiter is trimmed.
+			if multiline && i > 0 {
+				b.WriteRune(c)
+			} else {
+				b.WriteString(`\n`)
+			}
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		default:
+			if isTOMLControl(c) {
+				fmt.Fprintf(&b, `\u%04X`, c)
+			} else {
+				b.WriteRune(c)
+			}
+		}
+	}
+	if multiline {
+		return `"""` + b.String() + `"""`
+	}
+	return `"` + b.String() + `"`
+}
+
+func isTOMLControl(c rune) bool {
+	return c < 0x20 && c != '\t' && c != '\n' || c == 0x7f
+}
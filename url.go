@@ -0,0 +1,187 @@
+package anonymizer
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// SensitiveQueryParams lists the query parameters whose values RedactURL
+// masks, compared case-insensitively.
+var SensitiveQueryParams = []string{
+	"token", "access_token", "refresh_token", "id_token", "api_key", "apikey", "key",
+	"sig", "signature", "password", "passwd", "pwd", "secret", "client_secret", "auth", "code", "session",
+}
+
+// URLPathDetectors names the detectors of the DefaultRegistry run on the
+// path segments of urls.
+var URLPathDetectors = []string{
+	"email", "jwt", "guid", "high_entropy", "cc", "ssn", "aws_access_key", "github_token",
+	"google_api_key", "slack_token", "stripe_api_key",
+}
+
+// URLRedactor masks the secrets of urls: the password of the userinfo, the
+// values of sensitive query parameters, in the query or in a fragment
+// shaped like one, and the path segments and fragments in which detectors
+// find something.
+type URLRedactor struct {
+	// Params are the sensitive query parameters; SensitiveQueryParams when empty.
+	Params []string `json:"params"`
+	// Registry the path detectors come from; DefaultRegistry when nil.
+	Registry *Registry `json:"-"`
+	// Detectors run on path segments and fragments; URLPathDetectors when empty.
+	Detectors []string `json:"detectors"`
+	// Mask replaces the secrets; "REDACTED" when empty.
+	Mask string `json:"mask"`
+}
+
+// DefaultURLRedactor is the redactor used by RedactURL and RedactURLs.
+var DefaultURLRedactor = &URLRedactor{}
+
+// RedactURL masks the secrets of a url with the DefaultURLRedactor.
+func RedactURL(raw string) (string, error) {
+	return DefaultURLRedactor.Redact(raw)
+}
+
+// RedactURLs masks the secrets of the urls found in text with the
+// DefaultURLRedactor.
+func RedactURLs(text string) string {
+	return DefaultURLRedactor.RedactText(text)
+}
+
+// Redact masks the secrets of a url.
+func (r *URLRedactor) Redact(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" && u.Opaque == "" {
+		return "", errors.New("url host is null")
+	}
+	mask := r.mask()
+	if password, ok := u.User.Password(); ok && password != "" {
+		u.User = url.UserPassword(u.User.Username(), mask)
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = r.redactQuery(u.RawQuery, mask)
+	}
+	if path := u.EscapedPath(); path != "" {
+		segments := strings.Split(path, "/")
+		for i, segment := range segments {
+			if value, err := url.PathUnescape(segment); err == nil && r.sensitive(value) {
+				segments[i] = url.PathEscape(mask)
+			}
+		}
+		u.RawPath = strings.Join(segments, "/")
+		u.Path, _ = url.PathUnescape(u.RawPath)
+	}
+	if fragment := u.EscapedFragment(); fragment != "" {
+		if strings.Contains(fragment, "=") {
+			fragment = r.redactQuery(fragment, mask)
+		} else if r.sensitive(u.Fragment) {
+			fragment = url.PathEscape(mask)
+		}
+		u.RawFragment = fragment
+		u.Fragment, _ = url.PathUnescape(fragment)
+	}
+	return u.String(), nil
+}
+
+// RedactText masks the secrets of the urls found in text by LinkRegex.
+func (r *URLRedactor) RedactText(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, f := range regexFindings(text, "link", LinkRegex) {
+		if !isLinkURL(f.Value) {
+			continue
+		}
+		redacted, err := r.Redact(f.Value)
+		if err != nil {
+			continue
+		}
+		b.WriteString(text[last:f.Start])
+		b.WriteString(redacted)
+		last = f.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// Replace redacts url values, or the urls in free text. Its parameter adds
+// comma separated sensitive query parameters to the redactor's.
+func (r *URLRedactor) Replace(source any, name string) any {
+	switch field := source.(type) {
+	case reflect.Value:
+		field = indirect(field)
+		if field.Kind() != reflect.String {
+			return source
+		}
+		redactor := r
+		if name != "" {
+			extended := *r
+			extended.Params = append(append([]string{}, r.params()...), strings.Split(name, ",")...)
+			redactor = &extended
+		}
+		if redacted, err := redactor.Redact(field.String()); err == nil && strings.Contains(field.String(), "://") {
+			return redacted
+		}
+		return redactor.RedactText(field.String())
+	default:
+		return source
+	}
+}
+
+// redactQuery masks the sensitive parameters of a raw query, keeping their
+// order and encoding.
+func (r *URLRedactor) redactQuery(query, mask string) string {
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		key, _, ok := strings.Cut(param, "=")
+		if !ok {
+			continue
+		}
+		if name, err := url.QueryUnescape(key); err == nil && r.sensitiveParam(name) {
+			params[i] = key + "=" + url.QueryEscape(mask)
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+func (r *URLRedactor) sensitiveParam(name string) bool {
+	for _, param := range r.params() {
+		if strings.EqualFold(strings.TrimSpace(param), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sensitive reports whether a path detector finds something in value.
+func (r *URLRedactor) sensitive(value string) bool {
+	if value == "" {
+		return false
+	}
+	registry, detectors := r.Registry, r.Detectors
+	if registry == nil {
+		registry = defaultRegistry()
+	}
+	if len(detectors) == 0 {
+		detectors = URLPathDetectors
+	}
+	return len(registry.Find(value, detectors...)) > 0
+}
+
+func (r *URLRedactor) params() []string {
+	if len(r.Params) == 0 {
+		return SensitiveQueryParams
+	}
+	return r.Params
+}
+
+func (r *URLRedactor) mask() string {
+	if r.Mask == "" {
+		return "REDACTED"
+	}
+	return r.Mask
+}
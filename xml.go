@@ -0,0 +1,190 @@
+package anonymizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// AnonymizeXML anonymizes an XML document token by token. Rules pick what
+// they apply to through Rule.Field, a path selector:
+//
+//	/Patient/name/given  the text of the element at this path from the root
+//	given, //name/given  the text of the elements whose path ends so
+//	@ssn                 the ssn attribute of any element
+//	/Patient/@id, id/@root  the attribute of the elements selected as above
+//
+// Names are local names, or prefixed names as written in the document, and
+// "*" matches any name. Namespace declarations, comments, processing
+// instructions, CDATA sections and the untouched text are written back, as
+// is the whitespace around replaced text; empty elements are written as
+// self-closing tags.
+func AnonymizeXML(r io.Reader, w io.Writer, rules ...Rule) error {
+	in := &xmlRecorder{Reader: bufio.NewReader(r)}
+	dec := xml.NewDecoder(in)
+	out := bufio.NewWriter(w)
+	var path []xml.Name
+	pending := false
+	for {
+		start := dec.InputOffset()
+		tok, err := dec.RawToken()
+		cdata := in.cdata(start, dec.InputOffset())
+		if err == io.EOF {
+			if len(path) > 0 {
+				line, _ := dec.InputPos()
+				return &xml.SyntaxError{Msg: "unexpected EOF", Line: line}
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if pending {
+			if end, ok := tok.(xml.EndElement); ok && end.Name == path[len(path)-1] {
+				out.WriteString("/>")
+				path = path[:len(path)-1]
+				pending = false
+				continue
+			}
+			out.WriteByte('>')
+			pending = false
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name)
+			out.WriteString("<" + xmlName(t.Name))
+			for _, attr := range t.Attr {
+				value := attr.Value
+				if attr.Name.Space != "xmlns" && attr.Name.Local != "xmlns" {
+					value = xmlReplace(rules, path, &attr.Name, value)
+				}
+				out.WriteString(" " + xmlName(attr.Name) + `="` + escapeXML(value, true) + `"`)
+			}
+			pending = true
+		case xml.EndElement:
+			// RawToken does not check that elements are closed in order.
+			if len(path) == 0 || t.Name != path[len(path)-1] {
+				line, _ := dec.InputPos()
+				return &xml.SyntaxError{Msg: "unexpected end element </" + xmlName(t.Name) + ">", Line: line}
+			}
+			out.WriteString("</" + xmlName(t.Name) + ">")
+			path = path[:len(path)-1]
+		case xml.CharData:
+			text := string(t)
+			if value := strings.TrimSpace(text); value != "" && len(path) > 0 {
+				i := strings.Index(text, value)
+				text = text[:i] + xmlReplace(rules, path, nil, value) + text[i+len(value):]
+			}
+			if cdata {
+				out.WriteString("<![CDATA[" + strings.ReplaceAll(text, "]]>", "]]]]><![CDATA[>") + "]]>")
+				continue
+			}
+			out.WriteString(escapeXML(text, false))
+		case xml.Comment:
+			out.WriteString("<!--" + string(t) + "-->")
+		case xml.ProcInst:
+			out.WriteString("<?" + t.Target)
+			if len(t.Inst) > 0 {
+				out.WriteString(" " + string(t.Inst))
+			}
+			out.WriteString("?>")
+		case xml.Directive:
+			out.WriteString("<!" + string(t) + ">")
+		}
+	}
+	return out.Flush()
+}
+
+// xmlReplace applies the rules selecting the text of the element at path,
+// or its attribute when attr is set. The last applying rule wins, as in
+// AnonymizeMap.
+func xmlReplace(rules []Rule, path []xml.Name, attr *xml.Name, value string) string {
+	replaced := value
+	for _, rule := range rules {
+		if !xmlSelects(rule.Field, path, attr) || !rule.applies(rule.Field, value) {
+			continue
+		}
+		if ruler, ok := lookupReplacer(rule.Type); ok {
+			replaced = replaceString(ruler, value, rule.Value)
+		}
+	}
+	return replaced
+}
+
+// xmlSelects reports whether a selector selects the text of the element at
+// path, or its attribute when attr is set.
+func xmlSelects(selector string, path []xml.Name, attr *xml.Name) bool {
+	elements, attribute, isAttribute := strings.Cut(selector, "@")
+	if isAttribute != (attr != nil) || (isAttribute && !xmlNameMatches(attribute, *attr)) {
+		return false
+	}
+	elements = strings.TrimSuffix(elements, "/")
+	if elements == "" || elements == "/" {
+		return isAttribute
+	}
+	absolute := strings.HasPrefix(elements, "/") && !strings.HasPrefix(elements, "//")
+	segments := strings.Split(strings.TrimLeft(elements, "/"), "/")
+	if len(segments) > len(path) || (absolute && len(segments) != len(path)) {
+		return false
+	}
+	offset := len(path) - len(segments)
+	for i, segment := range segments {
+		if !xmlNameMatches(segment, path[offset+i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func xmlNameMatches(pattern string, name xml.Name) bool {
+	return pattern == "*" || pattern == name.Local || pattern == xmlName(name)
+}
+
+// xmlName returns a name as written, with its prefix.
+func xmlName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// xmlRecorder keeps the input the decoder reads, as RawToken does not tell
+// CDATA sections from text.
+type xmlRecorder struct {
+	*bufio.Reader
+	read   []byte
+	offset int64
+}
+
+func (r *xmlRecorder) ReadByte() (byte, error) {
+	b, err := r.Reader.ReadByte()
+	if err == nil {
+		r.read = append(r.read, b)
+	}
+	return b, err
+}
+
+// cdata reports whether the token read between the start and end offsets is
+// a CDATA section, and forgets the input before end.
+func (r *xmlRecorder) cdata(start, end int64) bool {
+	cdata := start >= r.offset && bytes.HasPrefix(r.read[start-r.offset:], []byte("<![CDATA["))
+	if end > r.offset && end-r.offset <= int64(len(r.read)) {
+		r.read = r.read[end-r.offset:]
+		r.offset = end
+	}
+	return cdata
+}
+
+var (
+	xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "\n", "&#xA;", "\r", "&#xD;", "\t", "&#x9;")
+)
+
+func escapeXML(s string, attribute bool) string {
+	if attribute {
+		return xmlAttrEscaper.Replace(s)
+	}
+	return xmlTextEscaper.Replace(s)
+}
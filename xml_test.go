@@ -0,0 +1,174 @@
+package anonymizer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+const patientXML = `<?xml version="1.0" encoding="UTF-8"?>
+<!-- export -->
+<Patient xmlns="http://hl7.org/fhir" xmlns:ext="urn:ext" id="p1" ssn="123-45-6789">
+  <name><given>Ana</given><family>Smith</family></name>
+  <contact><name><given>Bob</given></name></contact>
+  <ext:note ext:author="Dr. Who">Tom &amp; Jerry</ext:note>
+  <empty></empty>
+</Patient>
+`
+
+func anonymizeXML(t *testing.T, data string, rules ...Rule) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := AnonymizeXML(strings.NewReader(data), &buf, rules...); err != nil {
+		t.Fatalf("AnonymizeXML: %v", err)
+	}
+	return buf.String()
+}
+
+func TestAnonymizeXML(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		want  []string
+	}{
+		{
+			"absolute path",
+			[]Rule{{Field: "/Patient/name/given", Type: "asterisk"}},
+			[]string{"<given>***</given><family>Smith</family>", "<given>Bob</given>"},
+		},
+		{
+			"relative path",
+			[]Rule{{Field: "name/given", Type: "asterisk"}},
+			[]string{"<given>***</given><family>Smith</family>", "<given>***</given></name></contact>"},
+		},
+		{
+			"descendant path and wildcard",
+			[]Rule{{Field: "//contact/*/given", Type: "asterisk"}},
+			[]string{"<given>Ana</given>", "<given>***</given></name></contact>"},
+		},
+		{
+			"attributes",
+			[]Rule{{Field: "@ssn", Type: "asterisk"}, {Field: "/Patient/@id", Type: "empty"}},
+			[]string{`id="" ssn="***********"`},
+		},
+		{
+			"prefixed names",
+			[]Rule{{Field: "ext:note/@ext:author", Type: "asterisk"}, {Field: "note", Type: "asterisk"}},
+			[]string{`<ext:note ext:author="*******">***********</ext:note>`},
+		},
+		{
+			"allow list",
+			[]Rule{{Field: "given", Type: "asterisk", Allow: &List{Values: []string{"Bob"}}}},
+			[]string{"<given>***</given>", "<given>Bob</given>"},
+		},
+		{
+			"last rule wins",
+			[]Rule{{Field: "family", Type: "asterisk"}, {Field: "/Patient/name/family", Type: "empty"}},
+			[]string{"<family></family>"},
+		},
+	}
+	for _, tt := range tests {
+		got := anonymizeXML(t, patientXML, tt.rules...)
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: %q not in %s", tt.name, want, got)
+			}
+		}
+		for _, kept := range []string{`<?xml version="1.0" encoding="UTF-8"?>`, "<!-- export -->", `xmlns="http://hl7.org/fhir"`, `xmlns:ext="urn:ext"`, "<empty/>"} {
+			if !strings.Contains(got, kept) {
+				t.Errorf("%s: %q is not kept in %s", tt.name, kept, got)
+			}
+		}
+		if err := xml.Unmarshal([]byte(got), new(struct{})); err != nil {
+			t.Errorf("%s: invalid output: %v", tt.name, err)
+		}
+	}
+}
+
+// constReplacer replaces values by itself.
+type constReplacer string
+
+func (r constReplacer) Replace(source any, name string) any {
+	return string(r)
+}
+
+func TestAnonymizeXMLEscapes(t *testing.T) {
+	if err := AddCustomReplacer("xml_markup", constReplacer(`<a href="x">&</a>`)); err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveCustomReplacer("xml_markup")
+	got := anonymizeXML(t, `<r k="v">text</r>`, Rule{Field: "r", Type: "xml_markup"}, Rule{Field: "@k", Type: "xml_markup"})
+	if want := `<r k="&lt;a href=&quot;x&quot;&gt;&amp;&lt;/a&gt;">&lt;a href="x"&gt;&amp;&lt;/a&gt;</r>`; got != want {
+		t.Errorf("AnonymizeXML = %s, want %s", got, want)
+	}
+}
+
+func TestAnonymizeXMLUntouched(t *testing.T) {
+	if got := anonymizeXML(t, patientXML); got != strings.Replace(patientXML, "<empty></empty>", "<empty/>", 1) {
+		t.Errorf("AnonymizeXML without rules = %s", got)
+	}
+}
+
+func TestAnonymizeXMLErrors(t *testing.T) {
+	for _, data := range []string{"<a><b></a>", "<a></a></b>", "<a><b></b>", "<a x=1/>"} {
+		if err := AnonymizeXML(strings.NewReader(data), &bytes.Buffer{}); err == nil {
+			t.Errorf("AnonymizeXML(%s) succeeded", data)
+		}
+	}
+}
+
+func TestXMLSelects(t *testing.T) {
+	path := []xml.Name{{Local: "a"}, {Space: "p", Local: "b"}, {Local: "c"}}
+	attr := &xml.Name{Local: "id"}
+	tests := []struct {
+		selector string
+		attr     *xml.Name
+		want     bool
+	}{
+		{"/a/b/c", nil, true},
+		{"/a/p:b/c", nil, true},
+		{"/b/c", nil, false},
+		{"b/c", nil, true},
+		{"//c", nil, true},
+		{"c", attr, false},
+		{"@id", nil, false},
+		{"@id", attr, true},
+		{"@*", attr, true},
+		{"c/@id", attr, true},
+		{"/a/@id", attr, false},
+		{"x/a/b/c", nil, false},
+	}
+	for _, tt := range tests {
+		if got := xmlSelects(tt.selector, path, tt.attr); got != tt.want {
+			t.Errorf("xmlSelects(%q, attr %v) = %v, want %v", tt.selector, tt.attr != nil, got, tt.want)
+		}
+	}
+}
+
+func TestAnonymizeXMLCDATA(t *testing.T) {
+	data := "<r><a><![CDATA[Ana <b>]]></a><b>x<![CDATA[y]]></b><c><![CDATA[Bob]]></c></r>"
+	got := anonymizeXML(t, data, Rule{Field: "a", Type: "asterisk"})
+	if want := "<r><a><![CDATA[*******]]></a><b>x<![CDATA[y]]></b><c><![CDATA[Bob]]></c></r>"; got != want {
+		t.Errorf("AnonymizeXML = %s, want %s", got, want)
+	}
+	if err := AddCustomReplacer("xml_cdata_end", constReplacer("a]]>b")); err != nil {
+		t.Fatal(err)
+	}
+	defer RemoveCustomReplacer("xml_cdata_end")
+	got = anonymizeXML(t, data, Rule{Field: "a", Type: "xml_cdata_end"})
+	var v struct {
+		A string `xml:"a"`
+	}
+	if err := xml.Unmarshal([]byte(got), &v); err != nil || v.A != "a]]>b" {
+		t.Errorf("AnonymizeXML = %s, decoded %q, %v", got, v.A, err)
+	}
+}
+
+func TestAnonymizeXMLWhitespace(t *testing.T) {
+	data := "<r>\n  <name>\n    Ana Smith\n  </name>\n  <id> 42</id>\n</r>"
+	got := anonymizeXML(t, data, Rule{Field: "name", Type: "asterisk"}, Rule{Field: "id", Type: "asterisk"})
+	if want := "<r>\n  <name>\n    *********\n  </name>\n  <id> **</id>\n</r>"; got != want {
+		t.Errorf("AnonymizeXML = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,252 @@
+package anonymizer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AnonymizeYAML anonymizes the documents of a YAML stream. Rules apply to
+// the scalars of the keys named by Rule.Field, or of the dotted paths such
+// as "database.primary.password"; the string values without a rule are
+// redacted by the options' policy.
+//
+// The scalars replaced are edited in place in the text of the stream, so
+// everything else, comments, indentation and blank lines included, is
+// written back as read. Replaced scalars keep their anchor, tag and quotes;
+// plain scalars are quoted when their new value needs it and block scalars
+// become double-quoted. A stream in which a replaced scalar cannot be
+// located, such as a plain scalar spread over several lines, is encoded
+// from its node tree instead: comments, key order, anchors, flow style and
+// quotes are kept, but the indentation is the one of the options and blank
+// lines are dropped.
+func AnonymizeYAML(r io.Reader, w io.Writer, rules []Rule, opts ConfigOptions) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	a := &yamlAnonymizer{data: data, lines: []int{0}, rules: rules, policy: opts.policy(), located: true}
+	for i, c := range data {
+		if c == '\n' {
+			a.lines = append(a.lines, i+1)
+		}
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var docs []*yaml.Node
+	for {
+		doc := new(yaml.Node)
+		if err := dec.Decode(doc); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		a.node(doc, "", "", -1, false)
+		docs = append(docs, doc)
+	}
+	if a.located {
+		sort.Slice(a.edits, func(i, j int) bool { return a.edits[i].start < a.edits[j].start })
+		return writeEdits(w, data, a.edits)
+	}
+	enc := yaml.NewEncoder(w)
+	indent := opts.Indent
+	if indent == 0 {
+		indent = 2
+	}
+	enc.SetIndent(indent)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
+
+type yamlAnonymizer struct {
+	data   []byte
+	lines  []int
+	rules  []Rule
+	policy *Policy
+	edits  []configEdit
+	// located is false once a replaced scalar is not found in data.
+	located bool
+}
+
+// node anonymizes the scalars of node, found under key at path. indent is
+// the column of the block collection holding node, which the lines of a
+// block scalar are indented past, and flow whether node is in a flow
+// collection. Aliases are left to the anchored node they point to.
+func (a *yamlAnonymizer) node(node *yaml.Node, key, path string, indent int, flow bool) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			a.node(child, key, path, indent, flow)
+		}
+	case yaml.SequenceNode:
+		flow = flow || node.Style&yaml.FlowStyle != 0
+		for _, child := range node.Content {
+			a.node(child, key, path, node.Column-1, flow)
+		}
+	case yaml.MappingNode:
+		flow = flow || node.Style&yaml.FlowStyle != 0
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name := node.Content[i].Value
+			a.node(node.Content[i+1], name, joinPath(path, name), node.Content[i].Column-1, flow)
+		}
+	case yaml.ScalarNode:
+		tag := node.ShortTag()
+		if tag == "!!null" || tag == "!!binary" {
+			return
+		}
+		policy := a.policy
+		if tag != "!!str" {
+			// Only the rules of the key apply to numbers, booleans and
+			// times, which may then be replaced by strings.
+			policy = nil
+		}
+		if value, changed := anonymizeConfigValue(node.Value, key, path, a.rules, policy); changed {
+			old := node.Value
+			node.Value = value
+			if tag != "!!str" {
+				node.Tag, node.Style = "", node.Style&^yaml.TaggedStyle
+			}
+			a.edit(node, old, indent, flow)
+		}
+	}
+}
+
+// edit records the replacement of the text of node, a scalar whose value
+// was old.
+func (a *yamlAnonymizer) edit(node *yaml.Node, old string, indent int, flow bool) {
+	if !a.located || node.Line < 1 || node.Line > len(a.lines) {
+		a.located = false
+		return
+	}
+	start := a.lines[node.Line-1]
+	for i := 1; i < node.Column && start < len(a.data); i++ {
+		_, size := utf8.DecodeRune(a.data[start:])
+		start += size
+	}
+	end := a.scalarEnd(start, node.Style, indent, flow)
+	text, err := yamlScalar(node, flow)
+	if end < 0 || err != nil || !yamlScalarIs(a.data[start:end], old) {
+		a.located = false
+		return
+	}
+	a.edits = append(a.edits, configEdit{start: start, end: end, text: text})
+}
+
+// scalarEnd returns the end of the text of the scalar starting at start,
+// with its anchor and tag, or -1.
+func (a *yamlAnonymizer) scalarEnd(start int, style yaml.Style, indent int, flow bool) int {
+	data := a.data
+	i := start
+	for i < len(data) && (data[i] == '&' || data[i] == '!') {
+		for i < len(data) && data[i] != ' ' && data[i] != '\t' && data[i] != '\n' {
+			i++
+		}
+		for i < len(data) && (data[i] == ' ' || data[i] == '\t') {
+			i++
+		}
+	}
+	switch {
+	case style&yaml.DoubleQuotedStyle != 0:
+		for i++; i < len(data); i++ {
+			switch data[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1
+			}
+		}
+		return -1
+	case style&yaml.SingleQuotedStyle != 0:
+		for i++; i < len(data); i++ {
+			if data[i] == '\'' {
+				if i+1 < len(data) && data[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+		return -1
+	case style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		// The lines of a block scalar are the blank lines and the lines
+		// indented past its collection.
+		end := lineEnd(data, i)
+		for next := end + 1; next < len(data); {
+			eol := lineEnd(data, next)
+			line := data[next:eol]
+			if len(bytes.TrimSpace(line)) > 0 {
+				if len(line)-len(bytes.TrimLeft(line, " ")) <= indent {
+					break
+				}
+				end = eol
+			}
+			next = eol + 1
+		}
+		return end
+	}
+	// Plain scalars end with their line, before a comment or, in flow
+	// collections, before an indicator.
+	end := i
+	for ; end < len(data) && data[end] != '\n'; end++ {
+		if data[end] == '#' && end > i && (data[end-1] == ' ' || data[end-1] == '\t') {
+			break
+		}
+		if flow && (data[end] == ',' || data[end] == ']' || data[end] == '}') {
+			break
+		}
+	}
+	for end > i && (data[end-1] == ' ' || data[end-1] == '\t' || data[end-1] == '\r') {
+		end--
+	}
+	return end
+}
+
+// lineEnd returns the end of the line at i, before its line break.
+func lineEnd(data []byte, i int) int {
+	end := len(data)
+	if n := bytes.IndexByte(data[i:], '\n'); n >= 0 {
+		end = i + n
+	}
+	if end > i && data[end-1] == '\r' {
+		end--
+	}
+	return end
+}
+
+// yamlScalarIs reports whether raw is the text of a scalar of value value.
+// The line break ending the last line of block scalars is not in raw.
+func yamlScalarIs(raw []byte, value string) bool {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(append(raw[:len(raw):len(raw)], '\n'), &doc); err != nil || len(doc.Content) != 1 {
+		return false
+	}
+	return doc.Content[0].Kind == yaml.ScalarNode && doc.Content[0].Value == value
+}
+
+// yamlScalar encodes a replaced scalar on one line, keeping its anchor,
+// tag and quotes where its value allows.
+func yamlScalar(node *yaml.Node, flow bool) (string, error) {
+	n := &yaml.Node{Kind: yaml.ScalarNode, Tag: node.Tag, Value: node.Value, Style: node.Style}
+	quoted := n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0
+	if n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 || strings.Contains(n.Value, "\n") ||
+		flow && !quoted && strings.ContainsAny(n.Value, ",[]{}") {
+		n.Style = n.Style&yaml.TaggedStyle | yaml.DoubleQuotedStyle
+	}
+	out, err := yaml.Marshal(n)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSuffix(string(out), "\n")
+	if node.Anchor != "" {
+		text = "&" + node.Anchor + " " + text
+	}
+	return text, nil
+}